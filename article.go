@@ -6,11 +6,14 @@ import (
 	"io"
 	"log"
 	"math"
-	"os/exec"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"git.sr.ht/~ghost08/photon/imgproc"
 	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+	"git.sr.ht/~ghost08/photon/lib/media"
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-runewidth"
 	"golang.org/x/net/html"
@@ -18,50 +21,6 @@ import (
 
 var openedArticle *Article
 
-type ArticleMode int
-
-const (
-	ArticleContent ArticleMode = iota
-	CardDescription
-	CardContent
-)
-
-func (as ArticleMode) String() string {
-	switch as {
-	case ArticleContent:
-		return "ARTICLE"
-	case CardDescription:
-		return "DESCRIPTION"
-	case CardContent:
-		return "CONTENT"
-	}
-	return ""
-}
-
-func articleModeFromString(s string) ArticleMode {
-	switch s {
-	case "ARTICLE":
-		return ArticleContent
-	case "DESCRIPTION":
-		return CardDescription
-	case "CONTENT":
-		return CardContent
-	}
-	return ArticleContent
-}
-
-func (as ArticleMode) Next() ArticleMode {
-	switch as {
-	case ArticleContent:
-		return CardDescription
-	case CardDescription:
-		return CardContent
-	case CardContent:
-		return ArticleContent
-	}
-	return ArticleContent
-}
-
 type Article struct {
 	*lib.Article
 	scrollOffset int
@@ -71,20 +30,42 @@ type Article struct {
 
 	imgSixel       *imgproc.Sixel
 	underImageRune rune
+	imageYCells    int
+
+	search      string
+	searchFocus bool
+	matches     []articleMatch
+	matchIndex  int
+
+	// horizontal scroll of the preformatted lines
+	hscroll     int
+	maxPreWidth int
+	width       int
+
+	// the comments the contentLines were rendered from
+	renderedComments *lib.Comments
+	// the text of the text modes (lua, external command)
+	modeTexts map[ArticleMode]*modeText
 }
 
 func (a *Article) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScreen) Richtext {
 	s.Clear()
 	articleWidth := min(72, ctx.Width)
+	a.width = articleWidth
+	mode := a.mode()
+	if mode.stale != nil && mode.stale(a) {
+		a.contentLines = nil
+	}
 	if a.contentLines == nil {
-		switch a.Mode {
-		case ArticleContent:
-			a.contentLines = richtextFromArticle(a.Node, a.TextContent, articleWidth)
-		case CardDescription:
-			a.contentLines = richtextFromText(renderArticleContent(a.Card.Item.Description), articleWidth)
-		case CardContent:
-			a.contentLines = richtextFromText(renderArticleContent(a.Card.Item.Content), articleWidth)
+		a.contentLines = mode.render(a, articleWidth)
+		a.updateMatches()
+		a.maxPreWidth = 0
+		for _, line := range a.contentLines {
+			if line.isPre() {
+				a.maxPreWidth = max(a.maxPreWidth, line.Width())
+			}
 		}
+		a.hscroll = min(a.hscroll, max(0, a.maxPreWidth-articleWidth))
 	}
 	articleWidthPixels := articleWidth * ctx.XCellPixels
 	x := (ctx.Width - articleWidth) / 2
@@ -92,6 +73,9 @@ func (a *Article) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelSc
 	if a.imgSixel != nil {
 		imageYCells = a.imgSixel.Bounds.Dy() / ctx.YCellPixels
 	}
+	a.imageYCells = imageYCells
+	// the restored reading position can be after the end, when the article is narrower
+	a.scrollOffset = min(a.scrollOffset, max(0, len(a.contentLines)+imageYCells-1))
 	contentY := 7
 
 	// header
@@ -148,12 +132,13 @@ func (a *Article) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelSc
 
 	// content
 	for i := max(0, a.scrollOffset-imageYCells); i < len(a.contentLines); i++ {
-		line := a.contentLines[i]
+		line := a.highlightMatches(i, a.contentLines[i])
 		contentOffset := contentY + max(0, imageYCells-a.scrollOffset)
-		var lineOffset int
-		for _, to := range line {
-			lineOffset += drawString(s, x+lineOffset, contentOffset, to.Text, to.Style)
+		var offset int
+		if line.isPre() {
+			offset = a.hscroll
 		}
+		drawRichtext(s, x, contentOffset, articleWidth, offset, line)
 		a.lastLine = i
 		contentY++
 		if contentOffset >= ctx.Height {
@@ -164,11 +149,21 @@ func (a *Article) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelSc
 	// status bar text - article state + scroll percentage
 	above := a.scrollOffset
 	below := len(a.contentLines) - a.lastLine - 1
-	return Richtext{
+	status := Richtext{
 		{Text: a.Mode.String(), Style: tcell.StyleDefault.Foreground(tcell.ColorOrangeRed)},
 		{Text: "   ", Style: tcell.StyleDefault},
 		{Text: scrollPercentage(above, below), Style: tcell.StyleDefault},
 	}
+	if searchStatus := a.searchStatus(); searchStatus != "" {
+		status = append(
+			Richtext{
+				{Text: searchStatus, Style: tcell.StyleDefault.Foreground(tcell.ColorYellow)},
+				{Text: "   ", Style: tcell.StyleDefault},
+			},
+			status...,
+		)
+	}
+	return status
 }
 
 func (a *Article) Scroll(d int) {
@@ -183,15 +178,81 @@ func (a *Article) Scroll(d int) {
 	}
 }
 
+// ScrollHorizontal scrolls the preformatted lines (code blocks, tables)
+// that are wider than the article
+func (a *Article) ScrollHorizontal(d int) {
+	a.hscroll = max(0, min(a.hscroll+d, a.maxPreWidth-a.width))
+}
+
+// ToggleMode switches to the next mode that has something to show for the article
 func (a *Article) ToggleMode() {
-	a.Mode = a.Mode.Next()
+	modes := articleModes()
+	current := -1
+	for i, m := range modes {
+		if m.name == a.Mode {
+			current = i
+			break
+		}
+	}
+	for n := 1; n <= len(modes); n++ {
+		m := modes[(current+n+len(modes))%len(modes)]
+		if m.available == nil || m.available(a) {
+			a.SetMode(m.name)
+			return
+		}
+	}
+}
+
+// SetMode switches the article view to the mode,
+// and starts loading the content of the mode
+func (a *Article) SetMode(mode ArticleMode) {
+	a.Mode = mode
 	a.contentLines = nil
+	a.matches = nil
 	a.scrollOffset = 0
 	a.lastLine = 0
+	a.hscroll = 0
+	if m := a.mode(); m.load != nil {
+		m.load(a)
+	}
+}
+
+// restorePosition restores the mode and scroll position the article was left at
+func (a *Article) restorePosition() {
+	r, ok := a.Card.HistoryRecord()
+	if !ok || r.Mode == "" {
+		return
+	}
+	for _, m := range articleModes() {
+		if m.name == ArticleMode(r.Mode) {
+			a.SetMode(m.name)
+			break
+		}
+	}
+	a.scrollOffset = r.ScrollOffset
+}
+
+// savePosition stores the mode and scroll position of the article,
+// it's read when the end of the article was shown
+func (a *Article) savePosition() {
+	read := a.Mode == ArticleContent && a.contentLines != nil && a.lastLine >= len(a.contentLines)-1
+	a.Card.SaveReadingPosition(a.Mode.String(), a.scrollOffset, read)
+}
+
+// closeArticle saves the reading position and closes the article view
+func closeArticle(s tcell.Screen) {
+	if openedArticle != nil {
+		openedArticle.savePosition()
+	}
+	openedArticle = nil
+	photon.OpenedArticle = nil
+	s.Clear()
+	redraw(true)
 }
 
 func (a *Article) Clear() {
 	a.contentLines = nil
+	a.matches = nil
 	a.imgSixel = nil
 }
 
@@ -201,8 +262,18 @@ type textobject struct {
 	Text  string
 	Style tcell.Style
 	Link  string
+	// Prefix is drawn at the start of every line the text is wrapped to
+	// (list indentation, blockquote bar)
+	Prefix string
+	// Pre text isn't word wrapped, the lines are kept as they are
+	Pre bool
 }
 
+var (
+	prefixStyle = tcell.StyleDefault.Foreground(tcell.ColorGray)
+	codeStyle   = tcell.StyleDefault.Foreground(tcell.ColorTeal)
+)
+
 func (rt Richtext) Len() (length int) {
 	for _, to := range rt {
 		length += len(to.Text)
@@ -210,6 +281,24 @@ func (rt Richtext) Len() (length int) {
 	return length
 }
 
+// Width returns the width of the richtext in terminal cells
+func (rt Richtext) Width() (width int) {
+	for _, to := range rt {
+		width += runewidth.StringWidth(to.Text)
+	}
+	return width
+}
+
+// isPre reports if the line is a preformatted line
+func (rt Richtext) isPre() bool {
+	for _, to := range rt {
+		if to.Pre {
+			return true
+		}
+	}
+	return false
+}
+
 func richtextFromText(text string, width int) []Richtext {
 	return richtextWordWrap(
 		Richtext{{Text: text, Style: tcell.StyleDefault}},
@@ -217,6 +306,24 @@ func richtextFromText(text string, width int) []Richtext {
 	)
 }
 
+// richtextFromItemContent renders the item.Content/item.Description,
+// with the external article renderer if it's set or with the built-in html renderer
+func richtextFromItemContent(card *lib.Card, content string, width int) []Richtext {
+	if CLI.ArticleRenderer != "" {
+		return richtextFromText(renderArticleContent(card, content), width)
+	}
+	return richtextFromHTML(content, width)
+}
+
+func richtextFromHTML(h string, width int) []Richtext {
+	node, err := html.Parse(strings.NewReader(h))
+	if err != nil {
+		log.Println("ERROR: parsing html content:", err)
+		return richtextFromText(h, width)
+	}
+	return richtextFromArticle(node, "", width)
+}
+
 func richtextFromArticle(node *html.Node, textContent string, width int) []Richtext {
 	buf, err := parseArticleContent(node)
 	if err != nil {
@@ -239,201 +346,622 @@ func parseArticleContent(node *html.Node) (rt Richtext, err error) {
 		return
 	}
 	for node := node.FirstChild; node != nil; node = node.NextSibling {
-		switch node.Data {
-		case "html", "body", "header", "form":
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		subrt, err := parseArticleNode(node)
+		if err != nil {
+			return nil, err
+		}
+		rt = append(rt, subrt...)
+	}
+	return rt, nil
+}
+
+func parseArticleNode(node *html.Node) (rt Richtext, err error) {
+	if node.Type == html.TextNode {
+		rt = append(rt, textobject{
+			Style: tcell.StyleDefault,
+			Text:  collapseWhitespace(node.Data),
+		})
+		return rt, nil
+	}
+	if node.Type != html.ElementNode {
+		return nil, nil
+	}
+	switch node.Data {
+	case "html", "body", "header", "form":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		rt = append(rt, subrt...)
+	case "p", "section":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+	case "span":
+		color := tcell.ColorWhite
+		for _, attr := range node.Attr {
+			if attr.Key == "itemprop" && attr.Val == "description" {
+				color = tcell.ColorDarkGray
 			}
-			rt = append(rt, subrt...)
-		case "p", "section":
-			subrt, err := parseArticleContent(node)
+		}
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = tcell.StyleDefault.Foreground(color)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "a":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		href := getAttr(node, "href")
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Foreground(tcell.ColorOrangeRed).Bold(true).Url(href)
+				to.Link = href
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "i", "em", "small", "cite":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Italic(true)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "strong", "b":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Bold(true)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "u", "ins":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Underline(true)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "s", "del", "strike":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.StrikeThrough(true)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "mark":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Reverse(true)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "code", "kbd", "samp", "tt":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				_, bg, attrs := to.Style.Decompose()
+				fg, _, _ := codeStyle.Decompose()
+				to.Style = tcell.StyleDefault.Foreground(fg).Background(bg).Attributes(attrs)
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		underline := node.Data == "h1"
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Bold(true).Underline(underline)
+				return to
+			},
+		)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{
+			Style: tcell.StyleDefault.Bold(true),
+			Text:  "\n\n",
+		})
+	case "blockquote":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			trimNewlines(subrt),
+			func(to textobject) textobject {
+				to.Style = to.Style.Italic(true)
+				to.Prefix = "│ " + to.Prefix
+				return to
+			},
+		)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+	case "ul", "ol":
+		subrt, err := parseList(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		rt = append(rt, subrt...)
+	case "li":
+		subrt, err := parseListItem(node, "•")
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		rt = append(rt, subrt...)
+	case "dt":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Bold(true)
+				return to
+			},
+		)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+	case "dd":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			trimNewlines(subrt),
+			func(to textobject) textobject {
+				to.Prefix = "    " + to.Prefix
+				return to
+			},
+		)
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+	case "figcaption":
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		subrt = maprt(
+			subrt,
+			func(to textobject) textobject {
+				to.Style = to.Style.Italic(true).Foreground(tcell.ColorDarkGray)
+				return to
+			},
+		)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, subrt...)
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+	case "pre":
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, parsePre(node)...)
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+	case "table":
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, parseTable(node)...)
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+	case "br":
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+	case "hr":
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, textobject{Text: strings.Repeat("─", 24), Style: prefixStyle, Pre: true})
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+	case "svg", "img", "meta", "head", "title", "link", "script", "style", "noscript",
+		"iframe", "template", "button", "input", "select", "textarea":
+	default:
+		subrt, err := parseArticleContent(node)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+		rt = append(rt, subrt...)
+	}
+	return rt, nil
+}
+
+// parseList renders the <li> items of a <ul> or <ol> with bullets or numbers
+func parseList(node *html.Node) (rt Richtext, err error) {
+	n := 1
+	if start, err := strconv.Atoi(getAttr(node, "start")); err == nil {
+		n = start
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if child.Data != "li" {
+			subrt, err := parseArticleNode(child)
 			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+				return nil, err
 			}
 			rt = append(rt, subrt...)
-			rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
-		case "span":
-			color := tcell.ColorWhite
-			for _, attr := range node.Attr {
-				if attr.Key == "itemprop" && attr.Val == "description" {
-					color = tcell.ColorDarkGray
-				}
-			}
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+			continue
+		}
+		bullet := "•"
+		if node.Data == "ol" {
+			bullet = strconv.Itoa(n) + "."
+			n++
+		}
+		subrt, err := parseListItem(child, bullet)
+		if err != nil {
+			return nil, fmt.Errorf("parsing node <li>: %w", err)
+		}
+		rt = append(rt, subrt...)
+	}
+	// nested lists don't end with a empty line
+	end := "\n\n"
+	if node.Parent != nil && node.Parent.Data == "li" {
+		end = "\n"
+	}
+	rt = append(rt, textobject{Text: end, Style: tcell.StyleDefault})
+	return rt, nil
+}
+
+func parseListItem(node *html.Node, bullet string) (Richtext, error) {
+	subrt, err := parseArticleContent(node)
+	if err != nil {
+		return nil, err
+	}
+	indent := strings.Repeat(" ", runewidth.StringWidth(bullet)+1)
+	subrt = maprt(
+		trimNewlines(subrt),
+		func(to textobject) textobject {
+			to.Prefix = indent + to.Prefix
+			return to
+		},
+	)
+	rt := Richtext{
+		{Text: "\n", Style: tcell.StyleDefault},
+		{Text: bullet + " ", Style: tcell.StyleDefault.Bold(true)},
+	}
+	return append(rt, subrt...), nil
+}
+
+// parsePre returns the preformatted text of the node, the whitespace is kept
+// and the code is syntax highlighted
+func parsePre(node *html.Node) Richtext {
+	text := strings.TrimPrefix(textContent(node), "\n")
+	text = strings.TrimRight(text, " \t\n")
+	return highlightCode(text, codeLanguage(node))
+}
+
+const maxTableCellWidth = 40
+
+// parseTable renders the table rows as preformatted lines with aligned columns
+func parseTable(node *html.Node) Richtext {
+	type row struct {
+		cells  []string
+		header bool
+	}
+	var (
+		rows   []row
+		widths []int
+	)
+	var findRows func(*html.Node)
+	findRows = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.Data == "table" {
+				continue
 			}
-			subrt = maprt(
-				subrt,
-				func(to textobject) textobject {
-					to.Style = tcell.StyleDefault.Foreground(color)
-					return to
-				},
-			)
-			rt = append(rt, subrt...)
-		case "a":
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+			if child.Data != "tr" {
+				findRows(child)
+				continue
 			}
-			var href string
-			for _, attr := range node.Attr {
-				if attr.Key == "href" {
-					href = attr.Val
-					break
+			r := row{header: true}
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+					continue
 				}
+				if cell.Data == "td" {
+					r.header = false
+				}
+				text := runewidth.Truncate(collapseWhitespace(strings.TrimSpace(textContent(cell))), maxTableCellWidth, "…")
+				if len(widths) <= len(r.cells) {
+					widths = append(widths, 0)
+				}
+				widths[len(r.cells)] = max(widths[len(r.cells)], runewidth.StringWidth(text))
+				r.cells = append(r.cells, text)
 			}
-			subrt = maprt(
-				subrt,
-				func(to textobject) textobject {
-					to.Style = to.Style.Foreground(tcell.ColorOrangeRed).Bold(true).Url(href)
-					to.Link = href
-					return to
-				},
-			)
-			rt = append(rt, subrt...)
-		case "i", "em", "blockquote", "small":
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
-			}
-			subrt = maprt(
-				subrt,
-				func(to textobject) textobject {
-					to.Style = to.Style.Italic(true)
-					return to
-				},
-			)
-			rt = append(rt, subrt...)
-		case "strong", "b":
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+			if len(r.cells) > 0 {
+				rows = append(rows, r)
 			}
-			subrt = maprt(
-				subrt,
-				func(to textobject) textobject {
-					to.Style = to.Style.Bold(true)
-					return to
-				},
-			)
-			rt = append(rt, subrt...)
-		case "h1", "h2", "h3":
-			subrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+		}
+	}
+	findRows(node)
+
+	var rt Richtext
+	for i, r := range rows {
+		var line strings.Builder
+		for c, cell := range r.cells {
+			if c > 0 {
+				line.WriteString(" │ ")
 			}
-			subrt = maprt(
-				subrt,
-				func(to textobject) textobject {
-					to.Style = to.Style.Bold(true)
-					return to
-				},
-			)
-			rt = append(rt, subrt...)
-			rt = append(rt, textobject{
-				Style: tcell.StyleDefault.Bold(true),
-				Text:  "\n\n",
-			})
-		case "div":
-			divrt, err := parseArticleContent(node)
-			if err != nil {
-				return nil, fmt.Errorf("parsing node <%s>: %w", node.Data, err)
+			line.WriteString(runewidth.FillRight(cell, widths[c]))
+		}
+		style := tcell.StyleDefault
+		if r.header {
+			style = style.Bold(true)
+		}
+		rt = append(rt, textobject{Text: strings.TrimRight(line.String(), " ") + "\n", Style: style, Pre: true})
+		if r.header && i+1 < len(rows) && !rows[i+1].header {
+			var sep []string
+			for _, w := range widths {
+				sep = append(sep, strings.Repeat("─", w))
 			}
-			rt = append(rt, divrt...)
-		case "svg", "img", "meta", "head", "hr":
-		default:
-			if node != nil && node.Type == html.TextNode {
-				rt = append(rt, textobject{
-					Style: tcell.StyleDefault,
-					Text:  strings.TrimSpace(node.Data),
-				})
-				continue
+			rt = append(rt, textobject{Text: strings.Join(sep, "─┼─") + "\n", Style: prefixStyle, Pre: true})
+		}
+	}
+	return rt
+}
+
+// textContent returns the text of the node and all its descendants
+func textContent(node *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			sb.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			sb.WriteRune('\n')
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return sb.String()
+}
+
+func getAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// collapseWhitespace replaces all whitespace sequences with one space, like html does
+func collapseWhitespace(s string) string {
+	var sb strings.Builder
+	var space bool
+	for _, c := range s {
+		if unicode.IsSpace(c) {
+			if !space {
+				sb.WriteRune(' ')
 			}
+			space = true
+			continue
 		}
+		space = false
+		sb.WriteRune(c)
 	}
-	return rt, nil
+	return sb.String()
+}
+
+// trimNewlines removes the leading and trailing whitespace only textobjects
+func trimNewlines(rt Richtext) Richtext {
+	for len(rt) > 0 && !rt[0].Pre && strings.TrimSpace(rt[0].Text) == "" {
+		rt = rt[1:]
+	}
+	for len(rt) > 0 && !rt[len(rt)-1].Pre && strings.TrimSpace(rt[len(rt)-1].Text) == "" {
+		rt = rt[:len(rt)-1]
+	}
+	return rt
+}
+
+// wordWrapper splits the richtext to lines with the maximum width
+type wordWrapper struct {
+	width     int
+	lines     []Richtext
+	line      Richtext
+	lineWidth int
+	// line contains some text (not just the prefix)
+	lineText  bool
+	word      Richtext
+	wordWidth int
+	// a space is pending before the next word
+	space bool
 }
 
 func richtextWordWrap(buf Richtext, width int) []Richtext {
-	// word wrap with textobjects
-	var lines []Richtext
-	var line Richtext
-	var lineLength, wordLength int
-	var txt, word strings.Builder
+	w := &wordWrapper{width: width}
 	for _, to := range buf {
+		if to.Pre {
+			w.writePre(to)
+			continue
+		}
 		for _, c := range to.Text {
-			if c != '\n' && c != ' ' && wordLength < width {
-				word.WriteRune(c)
-				wordLength += runewidth.RuneWidth(c)
-				continue
+			switch {
+			case c == '\n':
+				w.flushWord()
+				w.newline()
+			case unicode.IsSpace(c):
+				w.flushWord()
+				w.space = true
+			default:
+				w.writeRune(to, c)
 			}
-			if lineLength+wordLength == width {
-				if wordLength > 0 {
-					txt.WriteString(word.String())
-				}
-				line = append(line, textobject{Text: txt.String(), Style: to.Style, Link: to.Link})
-				lines = append(lines, line)
-				line = nil
-				lineLength = 0
-				txt.Reset()
-				word.Reset()
-				wordLength = 0
-				continue
-			}
-			if c == '\n' || lineLength+wordLength > width {
-				line = append(line, textobject{Text: txt.String(), Style: to.Style, Link: to.Link})
-				lines = append(lines, line)
-				line = nil
-				txt.Reset()
-				if wordLength > 0 {
-					txt.WriteString(word.String())
-					if wordLength < width {
-						txt.WriteRune(' ')
-					}
-					lineLength = wordLength + 1
-					word.Reset()
-					wordLength = 0
-					continue
-				}
-				lineLength = 0
-				continue
+		}
+	}
+	w.flushWord()
+	if w.lineText {
+		w.breakLine()
+	}
+	return w.lines
+}
+
+func (w *wordWrapper) writeRune(to textobject, c rune) {
+	w.wordWidth += runewidth.RuneWidth(c)
+	if n := len(w.word); n > 0 && sameTextobjectKind(w.word[n-1], to) {
+		w.word[n-1].Text += string(c)
+		return
+	}
+	to.Text = string(c)
+	w.word = append(w.word, to)
+}
+
+// flushWord places the pending word on the line, or to the next line if it doesn't fit
+func (w *wordWrapper) flushWord() {
+	if w.wordWidth == 0 {
+		return
+	}
+	word := w.word
+	w.word = nil
+	wordWidth := w.wordWidth
+	w.wordWidth = 0
+	if w.lineText && w.space && w.lineWidth+1+wordWidth > w.width {
+		w.breakLine()
+	}
+	if !w.lineText {
+		w.startLine(word[0].Prefix)
+	} else if w.space {
+		space := word[0]
+		space.Text = " "
+		if last := w.line[len(w.line)-1]; last.Style != space.Style || last.Link != space.Link {
+			space.Style = tcell.StyleDefault
+			space.Link = ""
+		}
+		w.append(space, 1)
+	}
+	w.space = false
+	// the word is longer than the line, it has to be split
+	for _, to := range word {
+		for _, c := range to.Text {
+			rw := runewidth.RuneWidth(c)
+			if w.lineWidth+rw > w.width && w.lineText {
+				w.breakLine()
+				w.startLine(to.Prefix)
 			}
-			if wordLength > 0 {
-				txt.WriteString(word.String())
-				txt.WriteRune(' ')
-				lineLength += wordLength + 1
-				word.Reset()
-				wordLength = 0
+			part := to
+			part.Text = string(c)
+			w.append(part, rw)
+		}
+	}
+}
+
+// writePre places the lines of the preformatted text without wrapping
+func (w *wordWrapper) writePre(to textobject) {
+	w.flushWord()
+	w.space = false
+	if w.lineText && !w.line[len(w.line)-1].Pre {
+		w.breakLine()
+	}
+	for i, text := range strings.Split(strings.ReplaceAll(to.Text, "\t", "    "), "\n") {
+		if i > 0 {
+			if !w.lineText {
+				w.startLine(to.Prefix)
 			}
+			w.breakLine()
 		}
-		if wordLength == 0 {
+		if text == "" {
 			continue
 		}
-		if lineLength+wordLength > width {
-			line = append(line, textobject{Text: txt.String(), Style: to.Style, Link: to.Link})
-			lines = append(lines, line)
-			line = Richtext{textobject{
-				Text:  word.String() + " ",
-				Style: to.Style,
-				Link:  to.Link,
-			}}
-			txt.Reset()
-			word.Reset()
-			lineLength = wordLength + 1
-			wordLength = 0
-		} else {
-			txt.WriteString(word.String())
-			txt.WriteRune(' ')
-			line = append(line, textobject{Text: txt.String(), Style: to.Style, Link: to.Link})
-			txt.Reset()
-			word.Reset()
-			lineLength += wordLength + 1
-			wordLength = 0
+		if !w.lineText {
+			w.startLine(to.Prefix)
 		}
+		part := to
+		part.Text = text
+		w.append(part, runewidth.StringWidth(text))
+	}
+}
+
+func (w *wordWrapper) startLine(prefix string) {
+	w.lineText = true
+	if prefix == "" {
+		return
+	}
+	w.line = append(w.line, textobject{Text: prefix, Style: prefixStyle})
+	w.lineWidth += runewidth.StringWidth(prefix)
+}
+
+func (w *wordWrapper) append(to textobject, width int) {
+	w.lineWidth += width
+	to.Prefix = ""
+	if n := len(w.line); n > 0 && sameTextobjectKind(w.line[n-1], to) {
+		w.line[n-1].Text += to.Text
+		return
+	}
+	w.line = append(w.line, to)
+}
+
+func (w *wordWrapper) breakLine() {
+	w.lines = append(w.lines, w.line)
+	w.line = nil
+	w.lineWidth = 0
+	w.lineText = false
+}
+
+// newline ends the current line, on a empty line it adds a empty line,
+// but never more than one in a row
+func (w *wordWrapper) newline() {
+	w.space = false
+	if w.lineText {
+		w.breakLine()
+		return
+	}
+	if len(w.lines) == 0 || len(w.lines[len(w.lines)-1]) == 0 {
+		return
 	}
-	return lines
+	w.breakLine()
+}
+
+func sameTextobjectKind(a, b textobject) bool {
+	return a.Style == b.Style && a.Link == b.Link && a.Pre == b.Pre && a.Prefix == b.Prefix
 }
 
 func maprt(rts []textobject, f func(textobject) textobject) []textobject {
@@ -444,30 +972,41 @@ func maprt(rts []textobject, f func(textobject) textobject) []textobject {
 	return res
 }
 
-func renderArticleContent(h string) string {
+func renderArticleContent(card *lib.Card, h string) string {
 	if CLI.ArticleRenderer == "" {
 		return h
 	}
-	args := strings.Split(CLI.ArticleRenderer, " ")
-	if len(args) == 0 {
-		return h
+	r, err := pipeCommand(CLI.ArticleRenderer, h, card.CommandVars())
+	if err != nil {
+		return fmt.Sprintf("ERROR: article renderer: %s", err)
+	}
+	return r
+}
+
+// pipeCommand runs the command template with the input on it's stdin and returns it's output
+func pipeCommand(command, input string, vars cmdline.Vars) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return input, nil
+	}
+	c, err := cmdline.Command(command, nil, vars)
+	if err != nil {
+		return "", err
 	}
-	cmd := args[0]
-	args = args[1:]
-	c := exec.Command(cmd, args...)
 	in, err := c.StdinPipe()
 	if err != nil {
-		return fmt.Sprintf("ERROR: article renderer - opening stdin: %s", err)
+		return "", fmt.Errorf("opening stdin: %w", err)
 	}
 	go func() {
 		defer in.Close()
-		if _, err := io.WriteString(in, h); err != nil {
+		if _, err := io.WriteString(in, input); err != nil {
 			log.Println(err)
 		}
 	}()
-	r, err := c.CombinedOutput()
-	if err != nil {
-		return fmt.Sprintf("ERROR: article renderer: %s (%s)", err, string(r))
+	var out strings.Builder
+	c.Stdout, c.Stderr = &out, &out
+	info := media.ProcessInfo{Kind: media.ProcessRenderer, Title: vars["title"], Link: vars["url"]}
+	if err := photon.Processes().Run(c, info); err != nil {
+		return "", err
 	}
-	return string(r)
+	return out.String(), nil
 }
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"sync"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/export"
+	"github.com/gdamore/tcell/v2"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type ArticleMode string
+
+const (
+	ArticleContent  ArticleMode = "ARTICLE"
+	CardDescription ArticleMode = "DESCRIPTION"
+	CardContent     ArticleMode = "CONTENT"
+	ArticleComments ArticleMode = "COMMENTS"
+	ArticleSource   ArticleMode = "SOURCE"
+	ArticleMedia    ArticleMode = "MEDIA"
+)
+
+func (as ArticleMode) String() string {
+	return string(as)
+}
+
+// articleMode renders the content of the article view in one of the modes
+type articleMode struct {
+	name ArticleMode
+	// available reports if the mode has something to show for the article,
+	// modes that aren't available are skipped when toggling, nil means always available
+	available func(*Article) bool
+	// load is called when the mode is selected, to start loading it's content
+	load func(*Article)
+	// render returns the lines of the article content
+	render func(a *Article, width int) []Richtext
+	// stale reports if the content loaded in the background changed since it was rendered
+	stale func(*Article) bool
+}
+
+var builtinArticleModes = []articleMode{
+	{
+		name: ArticleContent,
+		render: func(a *Article, width int) []Richtext {
+			return richtextFromArticle(a.Node, a.TextContent, width)
+		},
+	},
+	{
+		name: CardDescription,
+		render: func(a *Article, width int) []Richtext {
+			return richtextFromItemContent(a.Card, a.Card.Item.Description, width)
+		},
+	},
+	{
+		name: CardContent,
+		render: func(a *Article, width int) []Richtext {
+			return richtextFromItemContent(a.Card, a.Card.Item.Content, width)
+		},
+	},
+	{
+		name: ArticleComments,
+		available: func(a *Article) bool {
+			return a.Card.CommentsFeed() != ""
+		},
+		load: func(a *Article) {
+			a.Card.LoadComments()
+		},
+		render: func(a *Article, width int) []Richtext {
+			a.renderedComments = a.Card.Comments()
+			return richtextFromComments(a.renderedComments, width)
+		},
+		stale: func(a *Article) bool {
+			return a.renderedComments != a.Card.Comments()
+		},
+	},
+	{
+		name:   ArticleSource,
+		render: richtextFromSource,
+	},
+	{
+		name:   ArticleMedia,
+		render: richtextFromMedia,
+	},
+}
+
+// cmdArticleModes are the modes from the --article-cmd-mode flags
+var cmdArticleModes []articleMode
+
+// parseCmdArticleModes parses the NAME=COMMAND article modes,
+// the text of the article is piped through the command
+func parseCmdArticleModes(specs []string) {
+	for _, spec := range specs {
+		name, command, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(command) == "" {
+			log.Printf("ERROR: article command mode `%s`: expected NAME=COMMAND", spec)
+			continue
+		}
+		command = strings.TrimSpace(command)
+		cmdArticleModes = append(cmdArticleModes, textArticleMode(
+			ArticleMode(strings.ToUpper(strings.TrimSpace(name))),
+			true,
+			func(a *Article) (string, error) {
+				return pipeCommand(command, a.TextContent, a.Card.CommandVars())
+			},
+		))
+	}
+}
+
+// articleModes returns the modes in the order they are toggled:
+// the built-in modes, the command modes and the modes added by lua plugins,
+// a mode replaces the earlier mode with the same name, in it's place
+func articleModes() []articleMode {
+	modes := make([]articleMode, 0, len(builtinArticleModes)+len(cmdArticleModes))
+	add := func(mode articleMode) {
+		for i, m := range modes {
+			if m.name == mode.name {
+				modes[i] = mode
+				return
+			}
+		}
+		modes = append(modes, mode)
+	}
+	for _, m := range builtinArticleModes {
+		add(m)
+	}
+	for _, m := range cmdArticleModes {
+		add(m)
+	}
+	if photon != nil {
+		for _, m := range photon.ArticleModes() {
+			render := m.Render
+			add(textArticleMode(
+				ArticleMode(m.Name),
+				false,
+				func(a *Article) (string, error) {
+					return render(a.Card)
+				},
+			))
+		}
+	}
+	return modes
+}
+
+// defaultArticleMode is the mode the articles are opened in, from the --article-mode flag
+var defaultArticleMode = ArticleContent
+
+// articleModeFromString returns the article mode by it's name, the built-in, command or lua plugin mode
+func articleModeFromString(s string) (ArticleMode, error) {
+	mode := ArticleMode(strings.ToUpper(s))
+	for _, m := range articleModes() {
+		if m.name == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown article mode `%s`", s)
+}
+
+// mode returns the current article mode, or the ARTICLE mode if it doesn't exist
+func (a *Article) mode() articleMode {
+	modes := articleModes()
+	for _, m := range modes {
+		if m.name == a.Mode {
+			return m
+		}
+	}
+	return modes[0]
+}
+
+// modeText is the text of a text mode, rendered in the background for async modes
+type modeText struct {
+	mu       sync.Mutex
+	text     string
+	done     bool
+	rendered bool
+}
+
+// textArticleMode creates a mode that shows the text returned by fn,
+// async modes run fn in the background, with a placeholder shown until it's done
+// (lua modes must run in the same goroutine as the other lua callbacks)
+func textArticleMode(name ArticleMode, async bool, fn func(*Article) (string, error)) articleMode {
+	run := func(a *Article, mt *modeText) {
+		text, err := fn(a)
+		if err != nil {
+			text = fmt.Sprintf("ERROR: %s mode: %s", name, err)
+		}
+		mt.mu.Lock()
+		mt.text, mt.done = text, true
+		mt.mu.Unlock()
+	}
+	return articleMode{
+		name: name,
+		load: func(a *Article) {
+			if a.modeTexts == nil {
+				a.modeTexts = make(map[ArticleMode]*modeText)
+			}
+			if _, ok := a.modeTexts[name]; ok {
+				return
+			}
+			mt := &modeText{}
+			a.modeTexts[name] = mt
+			if !async {
+				run(a, mt)
+				return
+			}
+			go func() {
+				run(a, mt)
+				redraw(false)
+			}()
+		},
+		render: func(a *Article, width int) []Richtext {
+			mt, ok := a.modeTexts[name]
+			if !ok {
+				return richtextFromText("", width)
+			}
+			mt.mu.Lock()
+			defer mt.mu.Unlock()
+			mt.rendered = mt.done
+			if !mt.done {
+				return richtextFromText("Loading…", width)
+			}
+			return richtextFromText(mt.text, width)
+		},
+		stale: func(a *Article) bool {
+			mt, ok := a.modeTexts[name]
+			if !ok {
+				return false
+			}
+			mt.mu.Lock()
+			defer mt.mu.Unlock()
+			return mt.done && !mt.rendered
+		},
+	}
+}
+
+// richtextFromSource shows the html of the page syntax highlighted, or the html of the article content
+// indented, when the page source isn't kept (offline articles)
+func richtextFromSource(a *Article, width int) []Richtext {
+	if a.Source != "" {
+		return richtextWordWrap(highlightCode(a.Source, "html"), width)
+	}
+	if a.Content == "" {
+		return richtextFromText(a.TextContent, width)
+	}
+	nodes, err := nethtml.ParseFragment(
+		strings.NewReader(a.Content),
+		&nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body},
+	)
+	if err != nil {
+		return richtextFromText(a.Content, width)
+	}
+	var sb strings.Builder
+	for _, node := range nodes {
+		formatHTML(&sb, node, 0)
+	}
+	return richtextWordWrap(highlightCode(sb.String(), "html"), width)
+}
+
+// formatHTML writes the html with every element on it's own line, indented by depth
+func formatHTML(sb *strings.Builder, node *nethtml.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch node.Type {
+	case nethtml.DocumentNode:
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			formatHTML(sb, child, depth)
+		}
+	case nethtml.TextNode:
+		if text := strings.Join(strings.Fields(node.Data), " "); text != "" {
+			sb.WriteString(indent + html.EscapeString(text) + "\n")
+		}
+	case nethtml.CommentNode:
+		sb.WriteString(indent + "<!--" + node.Data + "-->\n")
+	case nethtml.ElementNode:
+		sb.WriteString(indent + "<" + node.Data)
+		for _, attr := range node.Attr {
+			fmt.Fprintf(sb, ` %s="%s"`, attr.Key, html.EscapeString(attr.Val))
+		}
+		sb.WriteString(">\n")
+		if export.VoidElements[node.Data] {
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			formatHTML(sb, child, depth+1)
+		}
+		sb.WriteString(indent + "</" + node.Data + ">\n")
+	}
+}
+
+// richtextFromMedia lists the enclosures, media elements and extracted media links of the item
+func richtextFromMedia(a *Article, width int) []Richtext {
+	items := a.Card.MediaItems()
+	if len(items) == 0 {
+		return richtextFromText("No media", width)
+	}
+	var rt Richtext
+	for _, mi := range items {
+		rt = append(rt,
+			textobject{Text: "• ", Style: prefixStyle},
+			textobject{Text: mi.URL, Style: tcell.StyleDefault.Underline(true), Link: mi.URL},
+			textobject{Text: "\n", Style: tcell.StyleDefault},
+			textobject{Text: mediaItemInfo(mi), Style: prefixStyle, Prefix: "  "},
+			textobject{Text: "\n\n", Style: tcell.StyleDefault},
+		)
+	}
+	return richtextWordWrap(rt, width)
+}
+
+// mediaItemInfo returns the source, type, size and resolution of the media item
+func mediaItemInfo(mi lib.MediaItem) string {
+	info := []string{mi.Source}
+	switch {
+	case mi.Type != "":
+		info = append(info, mi.Type)
+	case mi.Medium != "":
+		info = append(info, mi.Medium)
+	}
+	if mi.Width > 0 && mi.Height > 0 {
+		info = append(info, fmt.Sprintf("%dx%d", mi.Width, mi.Height))
+	}
+	if mi.Size > 0 {
+		info = append(info, formatSize(mi.Size))
+	}
+	return strings.Join(info, " · ")
+}
+
+// formatSize returns the size in bytes in human readable form
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
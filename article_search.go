@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+var (
+	searchMatchStyle   = tcell.StyleDefault.Reverse(true)
+	searchCurrentStyle = tcell.StyleDefault.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+)
+
+// articleMatch is a search hit in the article contentLines,
+// start and end are byte offsets in the text of the line
+type articleMatch struct {
+	line       int
+	start, end int
+}
+
+// StartSearch focuses the article search input
+func (a *Article) StartSearch() {
+	a.search = ""
+	a.searchFocus = true
+	a.matches = nil
+	a.matchIndex = 0
+}
+
+// Search finds all the occurrences of the query in the article
+// and scrolls to the first one after the current scroll position
+func (a *Article) Search(query string) {
+	a.search = query
+	a.updateMatches()
+	a.matchIndex = 0
+	if len(a.matches) == 0 {
+		return
+	}
+	top := a.scrollOffset - a.imageYCells
+	for i, m := range a.matches {
+		if m.line >= top {
+			a.matchIndex = i
+			break
+		}
+	}
+	a.scrollToMatch()
+}
+
+// ClearSearch removes the search query and all highlighted matches
+func (a *Article) ClearSearch() {
+	a.search = ""
+	a.searchFocus = false
+	a.matches = nil
+	a.matchIndex = 0
+}
+
+// NextMatch scrolls to the next (d > 0) or previous (d < 0) search match
+func (a *Article) NextMatch(d int) {
+	if len(a.matches) == 0 {
+		return
+	}
+	a.matchIndex = (a.matchIndex + d) % len(a.matches)
+	if a.matchIndex < 0 {
+		a.matchIndex += len(a.matches)
+	}
+	a.scrollToMatch()
+}
+
+func (a *Article) scrollToMatch() {
+	m := a.matches[a.matchIndex]
+	firstLine := max(0, a.scrollOffset-a.imageYCells)
+	if m.line >= firstLine && m.line < a.lastLine {
+		return
+	}
+	a.scrollOffset = max(0, m.line+a.imageYCells)
+}
+
+// updateMatches searches the contentLines for the query,
+// the search is case insensitive unless the query contains a upper case letter
+func (a *Article) updateMatches() {
+	a.matches = nil
+	if a.search == "" || a.contentLines == nil {
+		return
+	}
+	expr := regexp.QuoteMeta(a.search)
+	if !strings.ContainsFunc(a.search, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return
+	}
+	for i, line := range a.contentLines {
+		for _, loc := range re.FindAllStringIndex(line.String(), -1) {
+			a.matches = append(a.matches, articleMatch{line: i, start: loc[0], end: loc[1]})
+		}
+	}
+	if a.matchIndex >= len(a.matches) {
+		a.matchIndex = 0
+	}
+}
+
+// highlightMatches returns the line with the search matches highlighted
+func (a *Article) highlightMatches(lineIndex int, line Richtext) Richtext {
+	if len(a.matches) == 0 {
+		return line
+	}
+	for i, m := range a.matches {
+		if m.line != lineIndex {
+			continue
+		}
+		style := searchMatchStyle
+		if i == a.matchIndex {
+			style = searchCurrentStyle
+		}
+		line = line.restyle(m.start, m.end, style)
+	}
+	return line
+}
+
+// searchStatus returns the match counter for the status bar
+func (a *Article) searchStatus() string {
+	if a.search == "" {
+		return ""
+	}
+	if len(a.matches) == 0 {
+		return "no matches"
+	}
+	return fmt.Sprintf("[%d/%d]", a.matchIndex+1, len(a.matches))
+}
+
+// String returns the text of the richtext without styles
+func (rt Richtext) String() string {
+	var sb strings.Builder
+	for _, to := range rt {
+		sb.WriteString(to.Text)
+	}
+	return sb.String()
+}
+
+// restyle splits the textobjects at the byte offsets start and end,
+// and sets the style of the text between them
+func (rt Richtext) restyle(start, end int, style tcell.Style) Richtext {
+	var (
+		res Richtext
+		pos int
+	)
+	for _, to := range rt {
+		toStart, toEnd := pos, pos+len(to.Text)
+		pos = toEnd
+		if toEnd <= start || toStart >= end {
+			res = append(res, to)
+			continue
+		}
+		from, to2 := max(start, toStart)-toStart, min(end, toEnd)-toStart
+		if from > 0 {
+			before := to
+			before.Text = to.Text[:from]
+			res = append(res, before)
+		}
+		match := to
+		match.Text = to.Text[from:to2]
+		match.Style = style
+		res = append(res, match)
+		if to2 < len(to.Text) {
+			after := to
+			after.Text = to.Text[to2:]
+			res = append(res, after)
+		}
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+)
+
+// autoDownloadMatches are the items shown in the auto-download view
+var autoDownloadMatches []lib.AutoDownloadMatch
+
+func openAutoDownload() {
+	autoDownloadMatches = photon.AutoDownloadMatches()
+	openList(&List{
+		Title: "Auto-download",
+		State: states.AutoDownload,
+		Rows:  autoDownloadRows,
+	})
+}
+
+func autoDownloadRows() []Richtext {
+	rows := make([]Richtext, len(autoDownloadMatches))
+	for i, m := range autoDownloadMatches {
+		status := "queued  "
+		switch {
+		case m.DryRun:
+			status = "dry run "
+		case m.Error != "":
+			status = "failed  "
+		}
+		info := " · " + m.Rule.Line
+		if m.Error != "" {
+			info = " · " + m.Error
+		}
+		rows[i] = Richtext{
+			{Text: status + "  ", Style: prefixStyle},
+			{Text: m.Card.Item.Title, Style: tcell.StyleDefault.Bold(true)},
+			{Text: info, Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// selectedAutoDownloadMatch returns the selected item
+func selectedAutoDownloadMatch() (lib.AutoDownloadMatch, bool) {
+	if openedList == nil || openedList.Selected() >= len(autoDownloadMatches) {
+		return lib.AutoDownloadMatch{}, false
+	}
+	return autoDownloadMatches[openedList.Selected()], true
+}
+
+func addAutoDownloadKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.AutoDownload, s)
+	photon.KeyBindings.Add(states.AutoDownload, "o", func() error {
+		if m, ok := selectedAutoDownloadMatch(); ok {
+			return m.Card.OpenBrowser()
+		}
+		return nil
+	})
+	// download the item manually, like the rule would
+	photon.KeyBindings.Add(states.AutoDownload, "dm", func() error {
+		if m, ok := selectedAutoDownloadMatch(); ok {
+			m.Card.DownloadMedia()
+		}
+		return nil
+	})
+}
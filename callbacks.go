@@ -1,7 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"image"
+	"log"
+	"sync"
+	"sync/atomic"
 
 	"git.sr.ht/~ghost08/photon/lib"
 	"git.sr.ht/~ghost08/photon/lib/states"
@@ -25,6 +29,10 @@ func (cb Callbacks) SelectedCardPos() image.Point {
 
 func (cb Callbacks) State() states.Enum {
 	switch {
+	case openedArticle != nil && openedArticle.searchFocus:
+		return states.ArticleSearch
+	case openedList != nil:
+		return openedList.State
 	case openedArticle != nil:
 		return states.Article
 	case command != "" && commandFocus:
@@ -36,6 +44,47 @@ func (cb Callbacks) State() states.Enum {
 
 func (cb Callbacks) ArticleChanged(article *lib.Article) {
 	openedArticle = &Article{Article: article}
+	openedArticle.SetMode(defaultArticleMode)
+	openedArticle.restorePosition()
+}
+
+// terminalMu lets one terminal program run at a time,
+// while it's running the screen is suspended and it isn't drawn,
+// drawMu is locked while a frame is drawn, so the screen is suspended after the frame is written
+var (
+	terminalMu        sync.Mutex
+	terminalSuspended atomic.Bool
+	drawMu            sync.Mutex
+)
+
+func (cb Callbacks) RunInTerminal(run func() error) error {
+	terminalMu.Lock()
+	defer terminalMu.Unlock()
+	if screen == nil {
+		return run()
+	}
+	drawMu.Lock()
+	terminalSuspended.Store(true)
+	if err := screen.Suspend(); err != nil {
+		terminalSuspended.Store(false)
+		drawMu.Unlock()
+		return fmt.Errorf("suspending the screen: %w", err)
+	}
+	drawMu.Unlock()
+	err := run()
+	drawMu.Lock()
+	if err := screen.Resume(); err != nil {
+		log.Println("ERROR: resuming the screen:", err)
+	}
+	terminalSuspended.Store(false)
+	drawMu.Unlock()
+	// the terminal program drew over the sixel images, they are drawn again
+	cb.grid.ClearCardsPosition()
+	if openedArticle != nil {
+		openedArticle.Clear()
+	}
+	redraw(true)
+	return err
 }
 
 func (cb Callbacks) Move() lib.Move {
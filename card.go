@@ -1,11 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"image"
 	"time"
 
 	"git.sr.ht/~ghost08/photon/imgproc"
 	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/media"
 	"github.com/gdamore/tcell/v2"
 	htime "github.com/sbani/go-humanizer/time"
 )
@@ -33,6 +35,9 @@ type Card struct {
 	sixelData        *imgproc.Sixel
 	previousImagePos image.Point
 	previousSelected bool
+	// previousPublished is the drawn published line, it changes with the offline, read and
+	// playback markers, without the card moving
+	previousPublished string
 }
 
 func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScreen, full bool) {
@@ -43,7 +48,8 @@ func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScree
 	imageMargin := (ctx.Width - imageWidthInCells) / 2
 	newImagePos := image.Point{ctx.X + 1 + imageMargin, ctx.Y + 1}
 	selected := c.Card == SelectedCard
-	if !full && c.previousImagePos.Eq(newImagePos) && selected == c.previousSelected {
+	published := c.publishedText()
+	if !full && c.previousImagePos.Eq(newImagePos) && selected == c.previousSelected && published == c.previousPublished {
 		return
 	}
 	style := tcell.StyleDefault
@@ -64,7 +70,7 @@ func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScree
 		}
 		drawLinesWordwrap(s, ctx.X+1, ctx.Y, ctx.Width-3, 2, c.Item.Title, style.Bold(true))
 		drawLine(s, ctx.X+1, ctx.Y+2, ctx.Width-3, c.Feed.Title, style.Italic(true))
-		drawLine(s, ctx.X+1, ctx.Y+3, ctx.Width-3, htime.Difference(time.Now(), *c.Item.PublishedParsed), style.Italic(true))
+		drawLine(s, ctx.X+1, ctx.Y+3, ctx.Width-3, published, style.Italic(true))
 		drawLinesWordwrap(s, ctx.X+1, ctx.Y+headerHeight+1, ctx.Width-3, ctx.Height-headerHeight-2, c.Item.Custom["simpleContent"], style)
 		return
 	}
@@ -98,7 +104,7 @@ func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScree
 	}
 	drawLine(s, ctx.X+1, ctx.Height-headerHeight+ctx.Y+2, ctx.Width-3, author, style.Italic(true))
 
-	drawLine(s, ctx.X+1, ctx.Height-headerHeight+ctx.Y+3, ctx.Width-3, htime.Difference(time.Now(), *c.Item.PublishedParsed), style.Italic(true))
+	drawLine(s, ctx.X+1, ctx.Height-headerHeight+ctx.Y+3, ctx.Width-3, published, style.Italic(true))
 
 	if c.DownloadImage(ctx) {
 		c.previousImagePos = image.Point{-2, -2}
@@ -115,6 +121,7 @@ func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScree
 	}
 	c.previousImagePos = newImagePos
 	c.previousSelected = selected
+	c.previousPublished = published
 	switch {
 	case newImagePos.Y < 0:
 		// if the image upper left corner is outside of the screen leave some upper sixel rows
@@ -129,6 +136,44 @@ func (c *Card) Draw(ctx Context, s tcell.Screen, sixelScreen *imgproc.SixelScree
 	}
 }
 
+// publishedText returns the episode number, the age of the item, the media duration (with the
+// position of a partly played media), the seeders and size of a torrent, the number of comments,
+// it's read and offline status
+func (c *Card) publishedText() string {
+	text := htime.Difference(time.Now(), *c.Item.PublishedParsed)
+	if episode := c.Episode(); episode != "" {
+		text = episode + " · " + text
+	}
+	if d := c.Duration(); d > 0 {
+		text += " · "
+		if pos, _ := c.MediaProgress(); pos > 0 {
+			text += media.FormatDuration(pos) + "/"
+		}
+		text += media.FormatDuration(d)
+	}
+	if t, ok := c.Torrent(); ok {
+		if t.Seeders >= 0 {
+			text += fmt.Sprintf(" · ↑%d", t.Seeders)
+			if t.Leechers >= 0 {
+				text += fmt.Sprintf(" ↓%d", t.Leechers)
+			}
+		}
+		if t.Size > 0 {
+			text += " · " + formatSize(t.Size)
+		}
+	}
+	if count, ok := c.CommentsCount(); ok {
+		text += fmt.Sprintf(" · %d comments", count)
+	}
+	if c.IsRead() {
+		text += " · read"
+	}
+	if c.OfflineReady() {
+		text += " · offline"
+	}
+	return text
+}
+
 func (c *Card) swapImageRegion(ctx Context, s tcell.Screen) {
 	selected := c.Card == SelectedCard
 	style := tcell.StyleDefault
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+	"git.sr.ht/~ghost08/photon/lib/states"
+)
+
+// clipboardCommands are the commands of the clipboard backends, the text is piped to their stdin
+var clipboardCommands = map[string]string{
+	"wl-copy": "wl-copy",
+	"xclip":   "xclip -selection clipboard",
+}
+
+// copyToClipboard copies the text with the --clipboard backend,
+// osc52, wl-copy, xclip or a command, that reads the text from the stdin
+func copyToClipboard(text string) error {
+	backend := strings.TrimSpace(CLI.Clipboard)
+	if backend == "" || backend == "osc52" {
+		osc52(text)
+		return nil
+	}
+	command, ok := clipboardCommands[backend]
+	if !ok {
+		command = backend
+	}
+	args, err := cmdline.Split(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("empty clipboard command")
+	}
+	cmd := exec.Command(args[0], args[1:]...) //nolint:gosec // we trust the user
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if out := strings.TrimSpace(string(out)); out != "" {
+			return fmt.Errorf("%w (%s)", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// osc52 writes the text in the OSC52 sequence to the terminal, it's written in one write,
+// between the frames of the draw loop, so it isn't mixed with the escape sequences of a frame
+// (the yanks run in their own goroutines)
+func osc52(text string) {
+	var b strings.Builder
+	b.WriteString("\033]52;c;") // Start OSC52
+	enc := base64.NewEncoder(base64.StdEncoding, &b)
+	enc.Write([]byte(text))
+	enc.Close()         // flush the last partial block
+	b.WriteString("\a") // End OSC52
+	drawMu.Lock()
+	defer drawMu.Unlock()
+	os.Stderr.WriteString(b.String())
+}
+
+// yankKeys are the keys of the yank actions in the card and the article view
+var yankKeys = []struct {
+	key  string
+	what string
+}{
+	{"yy", lib.YankLink},
+	{"yt", lib.YankTitle},
+	{"yl", lib.YankMarkdown},
+	{"ym", lib.YankMedia},
+	{"yi", lib.YankImage},
+	{"ya", lib.YankArticle},
+}
+
+func addYankKeyBindings() {
+	for _, y := range yankKeys {
+		what := y.what
+		photon.KeyBindings.Add(states.Normal, y.key, func() error {
+			SelectedCard.Yank(what, copyToClipboard)
+			return nil
+		})
+		photon.KeyBindings.Add(states.Article, y.key, func() error {
+			if openedArticle == nil {
+				return nil
+			}
+			openedArticle.Card.Yank(what, copyToClipboard)
+			return nil
+		})
+	}
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"image"
+	"unicode/utf8"
 
 	"git.sr.ht/~ghost08/photon/lib/states"
 	"github.com/gdamore/tcell/v2"
 )
 
 func drawCommand(ctx Context, s tcell.Screen) {
+	drawCommandLine(ctx, s, command)
+}
+
+func drawCommandLine(ctx Context, s tcell.Screen, text string) {
 	for i := range int(ctx.Cols) {
 		s.SetContent(i, int(ctx.Rows)-1, ' ', nil, tcell.StyleDefault)
 	}
@@ -16,7 +21,7 @@ func drawCommand(ctx Context, s tcell.Screen) {
 		0,
 		int(ctx.Rows-1),
 		int(ctx.Cols),
-		command,
+		text,
 		tcell.StyleDefault,
 	)
 }
@@ -50,3 +55,27 @@ func commandInput(s tcell.Screen, ev *tcell.EventKey) bool {
 	redraw(true)
 	return true
 }
+
+func articleSearchInput(s tcell.Screen, ev *tcell.EventKey) bool {
+	if cb.State() != states.ArticleSearch {
+		return false
+	}
+	if ev.Key() != tcell.KeyRune && ev.Key() != tcell.KeyBackspace && ev.Key() != tcell.KeyBackspace2 {
+		return false
+	}
+	query := openedArticle.search
+	if ev.Key() == tcell.KeyBackspace || ev.Key() == tcell.KeyBackspace2 {
+		if query == "" {
+			openedArticle.ClearSearch()
+			redraw(false)
+			return true
+		}
+		_, size := utf8.DecodeLastRuneInString(query)
+		query = query[:len(query)-size]
+	} else {
+		query += string(ev.Rune())
+	}
+	openedArticle.Search(query)
+	redraw(false)
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"github.com/gdamore/tcell/v2"
+	htime "github.com/sbani/go-humanizer/time"
+	"golang.org/x/net/html"
+)
+
+var commentAuthorStyle = tcell.StyleDefault.Foreground(tcell.ColorWhiteSmoke).Bold(true)
+
+// richtextFromComments renders the comment thread,
+// the replies are indented under their parent comment
+func richtextFromComments(comments *lib.Comments, width int) []Richtext {
+	switch {
+	case comments == nil || comments.Loading:
+		return richtextFromText("Loading comments…", width)
+	case comments.Err != nil:
+		return richtextFromText("Error loading comments: "+comments.Err.Error(), width)
+	case len(comments.Thread) == 0:
+		return richtextFromText("No comments", width)
+	}
+	var rt Richtext
+	var addComment func(c *lib.Comment, prefix string)
+	addComment = func(c *lib.Comment, prefix string) {
+		rt = append(rt, textobject{Text: c.Author, Style: commentAuthorStyle, Prefix: prefix})
+		if !c.Published.IsZero() {
+			rt = append(rt, textobject{
+				Text:   " · " + htime.Difference(time.Now(), c.Published),
+				Style:  prefixStyle,
+				Prefix: prefix,
+			})
+		}
+		rt = append(rt, textobject{Text: "\n", Style: tcell.StyleDefault})
+		rt = append(rt, commentContent(c.Content, prefix)...)
+		rt = append(rt, textobject{Text: "\n\n", Style: tcell.StyleDefault})
+		for _, reply := range c.Replies {
+			addComment(reply, prefix+"│ ")
+		}
+	}
+	for _, c := range comments.Thread {
+		addComment(c, "")
+	}
+	return richtextWordWrap(rt, width)
+}
+
+func commentContent(content, prefix string) Richtext {
+	node, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return Richtext{{Text: content, Style: tcell.StyleDefault, Prefix: prefix}}
+	}
+	rt, err := parseArticleContent(node)
+	if err != nil {
+		return Richtext{{Text: content, Style: tcell.StyleDefault, Prefix: prefix}}
+	}
+	return maprt(
+		trimNewlines(rt),
+		func(to textobject) textobject {
+			to.Prefix = prefix + to.Prefix
+			return to
+		},
+	)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"git.sr.ht/~ghost08/photon/lib/downloads"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+)
+
+// downloadsList are the downloads shown in the downloads view, it's updated on every draw
+var downloadsList []downloads.Download
+
+func openDownloads() {
+	openList(&List{
+		Title: "Downloads",
+		State: states.Downloads,
+		Rows:  downloadRows,
+	})
+}
+
+func downloadRows() []Richtext {
+	downloadsList = photon.Downloads().List()
+	rows := make([]Richtext, len(downloadsList))
+	for i, d := range downloadsList {
+		name := d.Title
+		if d.Path != "" {
+			name += " · " + filepath.Base(d.Path)
+		}
+		rows[i] = Richtext{
+			{Text: fmt.Sprintf("%-8s  ", d.Status), Style: prefixStyle},
+			{Text: name, Style: tcell.StyleDefault.Bold(true)},
+			{Text: downloadInfo(d), Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// downloadInfo returns the progress, size and speed of the download, or the error of a failed one
+func downloadInfo(d downloads.Download) string {
+	if d.Status == downloads.Failed {
+		return " · " + d.Error
+	}
+	info := ""
+	switch {
+	case d.Status == downloads.Done && d.Size > 0:
+		info = " · " + formatSize(d.Size)
+	case d.Progress() >= 0:
+		info = fmt.Sprintf(" · %.0f%% of %s", d.Progress()*100, formatSize(d.Size))
+	case d.Downloaded > 0:
+		info = " · " + formatSize(d.Downloaded)
+	}
+	if d.Speed > 0 {
+		info += " · " + formatSize(int64(d.Speed)) + "/s"
+	}
+	return info
+}
+
+// selectedDownload returns the selected download
+func selectedDownload() (downloads.Download, bool) {
+	if openedList == nil || openedList.Selected() >= len(downloadsList) {
+		return downloads.Download{}, false
+	}
+	return downloadsList[openedList.Selected()], true
+}
+
+func addDownloadsKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.Downloads, s)
+	action := func(f func(m *downloads.Manager, id int)) func() error {
+		return func() error {
+			if d, ok := selectedDownload(); ok {
+				f(photon.Downloads(), d.ID)
+			}
+			return nil
+		}
+	}
+	// pause or resume
+	photon.KeyBindings.Add(states.Downloads, "p", action((*downloads.Manager).Pause))
+	photon.KeyBindings.Add(states.Downloads, "c", action((*downloads.Manager).Cancel))
+	photon.KeyBindings.Add(states.Downloads, "r", action((*downloads.Manager).Retry))
+	photon.KeyBindings.Add(states.Downloads, "x", action((*downloads.Manager).Remove))
+}
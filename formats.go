@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+)
+
+// the card and the formats shown in the format picker,
+// the first row is the default format, so the formats are shifted by one
+var (
+	formatsCard *lib.Card
+	formats     []media.Format
+)
+
+// openFormats extracts the media of the card and opens the format picker
+func openFormats(card *lib.Card) {
+	if card == nil {
+		return
+	}
+	card.LoadMedia(func(m *media.Media) {
+		if len(m.Formats) == 0 {
+			photon.StatusWithTimeout("No formats, use --extractor-mode=json", time.Second*3)
+			return
+		}
+		formatsCard, formats = card, m.SortedFormats()
+		openList(&List{
+			Title: "Formats · " + card.Item.Title,
+			State: states.Formats,
+			Rows:  formatRows,
+		})
+	})
+}
+
+func formatRows() []Richtext {
+	rows := make([]Richtext, 0, len(formats)+1)
+	selected := ""
+	if m := formatsCard.LoadedMedia(); m != nil {
+		selected = m.Format
+	}
+	mark := "  "
+	if selected == "" {
+		mark = "▶ "
+	}
+	rows = append(rows, Richtext{
+		{Text: mark, Style: prefixStyle},
+		{Text: "default", Style: tcell.StyleDefault.Bold(true)},
+		{Text: " · best video and audio", Style: tcell.StyleDefault.Italic(true)},
+	})
+	for _, f := range formats {
+		mark := "  "
+		if f.ID == selected || strings.HasPrefix(selected, f.ID+"+") {
+			mark = "▶ "
+		}
+		rows = append(rows, Richtext{
+			{Text: mark, Style: prefixStyle},
+			{Text: formatName(f), Style: tcell.StyleDefault.Bold(true)},
+			{Text: formatInfo(f), Style: tcell.StyleDefault.Italic(true)},
+		})
+	}
+	return rows
+}
+
+// formatName returns the resolution of video formats and "audio" for audio only formats
+func formatName(f media.Format) string {
+	switch {
+	case f.AudioOnly():
+		return "audio"
+	case f.Height > 0:
+		return fmt.Sprintf("%dp", f.Height)
+	case f.Note != "":
+		return f.Note
+	}
+	return f.ID
+}
+
+// formatInfo returns the extension, codecs, fps, bitrate and size of the format
+func formatInfo(f media.Format) string {
+	info := []string{f.Ext}
+	if !f.AudioOnly() && f.VCodec != "" && f.VCodec != "none" {
+		info = append(info, f.VCodec)
+	}
+	if !f.VideoOnly() && f.ACodec != "" && f.ACodec != "none" {
+		info = append(info, f.ACodec)
+	}
+	if f.FPS > 0 {
+		info = append(info, fmt.Sprintf("%gfps", f.FPS))
+	}
+	if f.Bitrate > 0 {
+		info = append(info, fmt.Sprintf("%.0fk", f.Bitrate))
+	}
+	if f.Size > 0 {
+		info = append(info, formatSize(f.Size))
+	}
+	if f.VideoOnly() {
+		info = append(info, "+ best audio")
+	}
+	return " · " + strings.Join(info, " · ") + " · " + f.ID
+}
+
+func addFormatsKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.Formats, s)
+	// play the selected format
+	photon.KeyBindings.Add(states.Formats, "<enter>", func() error {
+		if openedList == nil || formatsCard == nil || formatsCard.LoadedMedia() == nil {
+			return nil
+		}
+		id := ""
+		if i := openedList.Selected(); i > 0 {
+			id = formats[i-1].ID
+		}
+		if err := formatsCard.SelectFormat(id); err != nil {
+			return err
+		}
+		card := formatsCard
+		closeList(s)
+		card.RunMedia()
+		return nil
+	})
+}
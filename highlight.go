@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/net/html"
+)
+
+// codeLanguage returns the language hint of a <pre> block,
+// from the class (language-go, lang-go, highlight-go) or data-lang attribute
+// of the <pre> or it's <code> child
+func codeLanguage(node *html.Node) string {
+	nodes := []*html.Node{node}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, n := range nodes {
+		if lang := getAttr(n, "data-lang"); lang != "" {
+			return lang
+		}
+		for _, class := range strings.Fields(getAttr(n, "class")) {
+			for _, prefix := range []string{"language-", "lang-", "highlight-source-", "highlight-"} {
+				if strings.HasPrefix(class, prefix) {
+					return strings.TrimPrefix(class, prefix)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// highlightCode returns the code as preformatted richtext,
+// colored by the lexer of the language, if the language is empty it's guessed
+func highlightCode(code, language string) Richtext {
+	plain := Richtext{{Text: code, Style: tcell.StyleDefault, Pre: true}}
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(language)
+	} else {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		return plain
+	}
+	lexer = chroma.Coalesce(lexer)
+	style := styles.Get(CLI.CodeStyle)
+	it, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return plain
+	}
+	var rt Richtext
+	for token := it(); token != chroma.EOF; token = it() {
+		rt = append(rt, textobject{
+			Text:  token.Value,
+			Style: tcellStyle(style.Get(token.Type)),
+			Pre:   true,
+		})
+	}
+	return rt
+}
+
+// tcellStyle converts the chroma style entry to tcell.Style,
+// the background is ignored, so the code has the terminal background
+func tcellStyle(entry chroma.StyleEntry) tcell.Style {
+	style := tcell.StyleDefault
+	if entry.Colour.IsSet() {
+		style = style.Foreground(tcell.NewRGBColor(
+			int32(entry.Colour.Red()),
+			int32(entry.Colour.Green()),
+			int32(entry.Colour.Blue()),
+		))
+	}
+	return style.
+		Bold(entry.Bold == chroma.Yes).
+		Italic(entry.Italic == chroma.Yes).
+		Underline(entry.Underline == chroma.Yes)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/history"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+	htime "github.com/sbani/go-humanizer/time"
+)
+
+// historyRecords are the records shown in the history view
+var historyRecords []history.Record
+
+func openHistory() {
+	historyRecords = photon.History()
+	openList(&List{
+		Title: "History",
+		State: states.History,
+		Rows:  historyRows,
+	})
+}
+
+func historyRows() []Richtext {
+	rows := make([]Richtext, len(historyRecords))
+	for i, r := range historyRecords {
+		kind := "article"
+		if r.Kind == history.KindMedia {
+			kind = "media  "
+		}
+		info := " · " + r.Feed + " · " + htime.Difference(time.Now(), r.OpenedAt)
+		if r.Read {
+			info += " · read"
+		}
+		rows[i] = Richtext{
+			{Text: kind + "  ", Style: prefixStyle},
+			{Text: r.Title, Style: tcell.StyleDefault.Bold(true)},
+			{Text: info, Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// selectedHistoryRecord returns the selected history record
+func selectedHistoryRecord() (history.Record, bool) {
+	if openedList == nil || len(historyRecords) == 0 {
+		return history.Record{}, false
+	}
+	return historyRecords[openedList.Selected()], true
+}
+
+func addHistoryKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.History, s)
+	// reopen the article, or play the media again
+	photon.KeyBindings.Add(states.History, "<enter>", func() error {
+		r, ok := selectedHistoryRecord()
+		if !ok {
+			return nil
+		}
+		card := photon.HistoryCard(r)
+		closeList(s)
+		if r.Kind == history.KindMedia {
+			card.RunMedia()
+			return nil
+		}
+		card.OpenArticle(context.Background())
+		return nil
+	})
+	photon.KeyBindings.Add(states.History, "p", func() error {
+		if r, ok := selectedHistoryRecord(); ok {
+			photon.HistoryCard(r).RunMedia()
+		}
+		return nil
+	})
+	photon.KeyBindings.Add(states.History, "o", func() error {
+		if r, ok := selectedHistoryRecord(); ok {
+			return photon.HistoryCard(r).OpenBrowser()
+		}
+		return nil
+	})
+}
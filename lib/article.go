@@ -1,31 +1,101 @@
 package lib
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"git.sr.ht/~ghost08/photon/imgproc"
+	"git.sr.ht/~ghost08/photon/lib/siteconfig"
+	"github.com/antchfx/htmlquery"
 	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
 type Article struct {
 	*readability.Article
 	Card     *Card
 	TopImage imgproc.ImageResizer
+	// Source is the html of the (first) page, as it was downloaded, decoded to UTF-8,
+	// it's empty for the offline and the plain text articles
+	Source string
 }
 
 func newArticle(ctx context.Context, card *Card, client *http.Client) (*Article, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, card.Item.Link, http.NoBody)
+	link := card.Item.Link
+	uri, err := url.Parse(link)
 	if err != nil {
 		return nil, err
 	}
-	uri, err := url.Parse(card.Item.Link)
+	rules := card.photon.siteConfigs.Lookup(uri.Hostname())
+	if rules != nil {
+		link = rules.RewriteURL(link)
+	}
+	pg, err := fetchPage(ctx, client, link)
+	if err != nil {
+		return nil, err
+	}
+	if pg.doc == nil {
+		return &Article{Article: plainTextArticle(card, pg.text), Card: card}, nil
+	}
+	doc, uri := pg.doc, pg.url
+	var next string
+	// a single page version of the article doesn't need the next pages
+	maxPages := card.photon.pageLimit(rules)
+	if maxPages > 1 && (rules == nil || rules.FindSinglePageLink(doc, uri) == "") {
+		next = nextPageLink(rules, doc, uri, uri, 1)
+	}
+	a, err := extractArticle(ctx, client, rules, doc, uri)
+	if err != nil {
+		return nil, err
+	}
+	if next != "" {
+		appendPages(ctx, client, rules, a, uri, next, maxPages)
+	}
+	return &Article{Article: a, Card: card, Source: pg.source}, nil
+}
+
+// extractArticle extracts the article from the page with the site config rules if there are any,
+// or with readability
+func extractArticle(ctx context.Context, client *http.Client, rules *siteconfig.Config, doc *html.Node, uri *url.URL) (*readability.Article, error) {
+	if rules != nil {
+		return extractWithRules(ctx, client, rules, doc, uri)
+	}
+	a, err := readability.FromDocument(doc, uri)
 	if err != nil {
 		return nil, err
 	}
+	return &a, nil
+}
+
+// page is a fetched web page, doc is nil for plain text pages
+type page struct {
+	doc *html.Node
+	// source is the html of the doc
+	source string
+	text   string
+	// url of the page after redirects
+	url *url.URL
+}
+
+// fetchPage downloads the page and decodes it to UTF-8,
+// html and xml pages are parsed, text and markdown pages are kept as plain text
+func fetchPage(ctx context.Context, client *http.Client, link string) (*page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	uri := req.URL
 
 	if client == nil {
 		client = &http.Client{
@@ -49,48 +119,123 @@ func newArticle(ctx context.Context, card *Card, client *http.Client) (*Article,
 	}
 	defer resp.Body.Close()
 
-	if mime := resp.Header.Get("Content-Type"); !isValidContentType(mime) {
-		return nil, fmt.Errorf("invalid content-type `%s`", mime)
+	body := bufio.NewReader(resp.Body)
+	// header is the content-type of the response, the sniffed one has always the utf-8 charset,
+	// so it isn't used for decoding
+	header := resp.Header.Get("Content-Type")
+	contentType := header
+	if contentType == "" {
+		head, _ := body.Peek(512)
+		contentType = http.DetectContentType(head)
 	}
-
-	a, err := readability.FromReader(resp.Body, uri)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && !errors.Is(err, mime.ErrInvalidMediaParameter) {
+		return nil, fmt.Errorf("invalid content-type `%s`: %w", contentType, err)
+	}
+	if !isHTMLMediaType(mediaType) && !isTextMediaType(mediaType) {
+		return nil, fmt.Errorf("unsupported content-type `%s`", contentType)
+	}
+	// the charset is taken from the content-type, the BOM, <meta charset> or sniffed
+	r, err := charset.NewReader(body, header)
+	if err != nil {
+		return nil, fmt.Errorf("decoding charset of `%s`: %w", contentType, err)
+	}
+	pg := &page{url: resp.Request.URL}
+	if isHTMLMediaType(mediaType) {
+		source, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		pg.source = string(source)
+		pg.doc, err = html.Parse(bytes.NewReader(source))
+		if err != nil {
+			return nil, fmt.Errorf("parsing html: %w", err)
+		}
+		return pg, nil
+	}
+	text, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return &Article{Article: &a, Card: card}, nil
+	pg.text = string(text)
+	return pg, nil
 }
 
-func isValidContentType(mime string) bool {
-	switch mime {
-	case "text/html":
-		return true
-	case "application/rss+xml":
-		return true
-	case "text/html;charset=utf-8":
-		return true
-	case "text/html;charset=UTF-8":
+func isHTMLMediaType(mediaType string) bool {
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "application/xml", "text/xml":
 		return true
-	case "text/html; charset=utf-8":
-		return true
-	case "text/html; charset=UTF-8":
-		return true
-	case "text/html; charset=iso-8859-1":
-		return true
-	case "text/html; charset=ISO-8859-1":
-		return true
-	case "text/html; charset=\"utf-8\"":
-		return true
-	case "text/html; charset=\"UTF-8\"":
-		return true
-	case "text/html;;charset=UTF-8":
-		return true
-	case "text/plain; charset=utf-8":
-		return true
-	case "text/plain; charset=UTF-8":
-		return true
-	case "application/rss+xml; charset=utf-8":
-		return true
-	default:
-		return false
 	}
+	// application/rss+xml, application/atom+xml, ...
+	return strings.HasSuffix(mediaType, "+xml")
+}
+
+// isTextMediaType reports if the page is shown as plain text,
+// text/plain, text/markdown and the other text types
+func isTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// plainTextArticle creates a article from a text or markdown page,
+// it has no html content so the article view shows the text as it is
+func plainTextArticle(card *Card, text string) *readability.Article {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	excerpt, _, _ := strings.Cut(text, "\n\n")
+	return &readability.Article{
+		Title:       card.Item.Title,
+		TextContent: text,
+		Length:      len(text),
+		Excerpt:     excerpt,
+	}
+}
+
+// extractWithRules extracts the article with the site config rules,
+// readability is used for the metadata the rules don't cover,
+// and for the content when the body rules don't match
+func extractWithRules(ctx context.Context, client *http.Client, rules *siteconfig.Config, doc *html.Node, uri *url.URL) (*readability.Article, error) {
+	if link := rules.FindSinglePageLink(doc, uri); link != "" && link != uri.String() {
+		switch single, err := fetchPage(ctx, client, link); {
+		case err != nil:
+			log.Println("ERROR: fetching single page version:", err)
+		case single.doc != nil:
+			doc, uri = single.doc, single.url
+		}
+	}
+	rules.StripNodes(doc)
+	body, err := rules.FindBody(doc)
+	if err != nil {
+		return nil, fmt.Errorf("rendering site config body: %w", err)
+	}
+	if body == "" && !rules.AutodetectOnFailure {
+		return nil, fmt.Errorf("site config body not found on %s", uri)
+	}
+	a, err := readability.FromDocument(doc, uri)
+	if err != nil && body == "" {
+		return nil, err
+	}
+	if body != "" {
+		bodyDoc, err := html.Parse(strings.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("parsing site config body: %w", err)
+		}
+		if rules.Prune {
+			if pruned, err := readability.FromDocument(bodyDoc, uri); err == nil && pruned.Node != nil {
+				bodyDoc, body = pruned.Node, pruned.Content
+			}
+		}
+		a.Node = bodyDoc
+		a.Content = body
+		a.TextContent = strings.TrimSpace(htmlquery.InnerText(bodyDoc))
+		a.Length = len(a.TextContent)
+	}
+	if title := rules.FindTitle(doc); title != "" {
+		a.Title = title
+	}
+	if author := rules.FindAuthor(doc); author != "" {
+		a.Byline = author
+	}
+	if image := rules.FindImage(doc, uri); image != "" {
+		a.Image = image
+	}
+	return &a, nil
 }
@@ -0,0 +1,47 @@
+package lib
+
+import (
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// ArticleMode is a article view mode added by a lua plugin,
+// Render returns the text shown in the article view
+type ArticleMode struct {
+	Name   string
+	Render func(*Card) (string, error)
+}
+
+// ArticleModes returns the article view modes added by the lua plugins
+func (p *Photon) ArticleModes() []ArticleMode {
+	return p.articleModes
+}
+
+// addArticleMode registers a article view mode, the lua function gets the card
+// and returns the text of the mode, a mode with the same name is replaced
+func (p *Photon) addArticleMode(L *lua.LState) int {
+	name := strings.ToUpper(L.CheckString(1))
+	fn := L.CheckFunction(2)
+	mode := ArticleMode{
+		Name: name,
+		Render: func(card *Card) (string, error) {
+			L.Push(fn)
+			L.Push(newCard(card, L))
+			if err := L.PCall(1, 1, nil); err != nil {
+				return "", err
+			}
+			ret := L.Get(-1)
+			L.Pop(1)
+			return lua.LVAsString(ret), nil
+		},
+	}
+	for i, m := range p.articleModes {
+		if m.Name == name {
+			p.articleModes[i] = mode
+			return 0
+		}
+	}
+	p.articleModes = append(p.articleModes, mode)
+	return 0
+}
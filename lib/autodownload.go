@@ -0,0 +1,249 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/autodownload"
+	"git.sr.ht/~ghost08/photon/lib/downloads"
+)
+
+// autoDownloader are the settings and the state of the rule based downloading of new items
+type autoDownloader struct {
+	enabled bool
+	dryRun  bool
+	rules   []autodownload.Rule
+	history *autodownload.History
+
+	// running is locked by the run, so the runs don't download the same items
+	running sync.Mutex
+	// seeded is set after the first run recorded the current items, the dry run doesn't save them
+	seeded  bool
+	mu      sync.Mutex
+	matches []AutoDownloadMatch
+}
+
+// AutoDownloadMatch is a item matched by a auto-download rule in the last run
+type AutoDownloadMatch struct {
+	Card *Card
+	Rule autodownload.Rule
+	// DryRun is set, when the item wasn't downloaded, because of the dry run
+	DryRun bool
+	// Error is set, when the download couldn't be started
+	Error string
+}
+
+// WithAutoDownload loads the auto-download rules from the file at rulesPath (the config
+// autodownload file if empty), with dryRun the matching items are only listed
+func WithAutoDownload(rulesPath string, dryRun bool) Option {
+	return func(p *Photon) {
+		if rulesPath == "" {
+			rulesPath = configDir("autodownload")
+		}
+		f, err := os.Open(rulesPath)
+		if err != nil {
+			log.Println("ERROR: opening auto-download rules:", err)
+			return
+		}
+		defer f.Close()
+		rules, err := autodownload.Parse(f)
+		if err != nil {
+			log.Printf("ERROR: parsing auto-download rules (%s): %s", rulesPath, err)
+		}
+		history, err := autodownload.LoadHistory(cacheDir("autodownload.json"))
+		if err != nil {
+			log.Println("ERROR: loading auto-download history:", err)
+		}
+		p.autoDownloader = &autoDownloader{
+			enabled: len(rules) > 0,
+			dryRun:  dryRun,
+			rules:   rules,
+			history: history,
+		}
+	}
+}
+
+// AutoDownloadMatches returns the items matched by the auto-download rules in the last run
+func (p *Photon) AutoDownloadMatches() []AutoDownloadMatch {
+	if p.autoDownloader == nil {
+		return nil
+	}
+	p.autoDownloader.mu.Lock()
+	defer p.autoDownloader.mu.Unlock()
+	return p.autoDownloader.matches
+}
+
+// autoDownload downloads the cards matching the auto-download rules, that weren't downloaded before,
+// the first matching rule is used, the first run only records the current items in the history
+func (p *Photon) autoDownload(cards Cards) {
+	ad := p.autoDownloader
+	if !ad.running.TryLock() {
+		return
+	}
+	defer ad.running.Unlock()
+	if !ad.history.Saved() && !ad.seeded {
+		ad.seeded = true
+		p.seedAutoDownloadHistory(cards)
+		return
+	}
+	// the items with a download in the download manager are downloaded, or they were canceled,
+	// only the failed downloads are tried again
+	queued := make(map[string]bool)
+	for _, d := range p.downloads.List() {
+		if d.Status != downloads.Failed {
+			queued[d.ItemLink] = true
+		}
+	}
+	var matches []AutoDownloadMatch
+	for _, card := range cards {
+		id := card.autoDownloadID()
+		if id == "" || ad.history.Has(id) || ad.history.Has(card.Item.Link) || queued[card.Item.Link] {
+			continue
+		}
+		fields := card.autoDownloadFields()
+		for _, rule := range ad.rules {
+			if !rule.Match(fields) {
+				continue
+			}
+			m := AutoDownloadMatch{Card: card, Rule: rule, DryRun: ad.dryRun}
+			if !ad.dryRun {
+				// the item is recorded in the history, when the download finishes
+				if err := card.autoDownload(rule); err != nil {
+					log.Printf("ERROR: auto-download (%s): %s", card.Item.Link, err)
+					m.Error = err.Error()
+				}
+			}
+			matches = append(matches, m)
+			break
+		}
+	}
+	ad.mu.Lock()
+	ad.matches = matches
+	ad.mu.Unlock()
+	if len(matches) == 0 {
+		return
+	}
+	if ad.dryRun {
+		p.StatusWithTimeout(
+			fmt.Sprintf("Auto-download dry run: %d items would be downloaded", len(matches)),
+			time.Second*5,
+		)
+		return
+	}
+	p.StatusWithTimeout(fmt.Sprintf("Auto-download: %d items", len(matches)), time.Second*3)
+}
+
+// seedAutoDownloadHistory records the current items in the empty history, so the rules download
+// only the items published after the first run, not the whole feeds,
+// the dry run records them only in memory
+func (p *Photon) seedAutoDownloadHistory(cards Cards) {
+	ad := p.autoDownloader
+	ids := make([]string, 0, len(cards))
+	for _, card := range cards {
+		if id := card.autoDownloadID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	ad.mu.Lock()
+	ad.matches = nil
+	ad.mu.Unlock()
+	if ad.dryRun {
+		ad.history.Remember(ids...)
+		p.StatusWithTimeout(
+			fmt.Sprintf("Auto-download dry run: first run, %d items recorded, none would be downloaded", len(ids)),
+			time.Second*5,
+		)
+		return
+	}
+	if err := ad.history.Add(ids...); err != nil {
+		log.Println("ERROR: saving auto-download history:", err)
+		return
+	}
+	p.StatusWithTimeout(
+		fmt.Sprintf("Auto-download: first run, %d items recorded, none downloaded", len(ids)),
+		time.Second*5,
+	)
+}
+
+// autoDownloadFinished records the item of the finished download in the auto-download history,
+// the items downloaded by hand are recorded too, so the rules don't download them again
+func (p *Photon) autoDownloadFinished(d downloads.Download) {
+	ad := p.autoDownloader
+	if ad == nil || !ad.enabled || ad.dryRun || d.ItemLink == "" {
+		return
+	}
+	id := d.ItemLink
+	if card := p.DownloadCard(d); card != nil {
+		id = card.autoDownloadID()
+	}
+	if err := ad.history.Add(id); err != nil {
+		log.Println("ERROR: saving auto-download history:", err)
+	}
+}
+
+// autoDownloadID returns the id of the item in the auto-download history, the guid or the link
+func (card *Card) autoDownloadID() string {
+	if card.Item.GUID != "" {
+		return card.Item.GUID
+	}
+	return card.Item.Link
+}
+
+// autoDownloadFields returns the item values matched by the auto-download conditions
+func (card *Card) autoDownloadFields() map[string]string {
+	fields := map[string]string{
+		"feedurl":     card.FeedInput,
+		"title":       card.Item.Title,
+		"link":        card.Item.Link,
+		"description": card.Item.Description,
+	}
+	if card.Feed != nil {
+		fields["feed"] = card.Feed.Title
+	}
+	if card.Item.Author != nil {
+		fields["author"] = card.Item.Author.Name
+	}
+	return fields
+}
+
+// autoDownload queues the download of the card's media, link or image by the rule
+func (card *Card) autoDownload(rule autodownload.Rule) error {
+	var (
+		dir string
+		err error
+	)
+	if rule.Dir == "" {
+		dir, err = card.downloadDir()
+	} else if dir, err = expandHome(rule.Dir); err == nil {
+		err = os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return fmt.Errorf("download path: %w", err)
+	}
+	var links []string
+	switch rule.What {
+	case autodownload.Media:
+		if link, ok := card.torrentFile(); ok {
+			links = []string{link}
+			break
+		}
+		m, err := card.GetMedia()
+		if err != nil {
+			return fmt.Errorf("extracting media link: %w", err)
+		}
+		links = m.Links
+	case autodownload.Link:
+		links = []string{card.Item.Link}
+	case autodownload.Image:
+		if card.Item.Image == nil {
+			return errors.New("item has no image")
+		}
+		links = []string{card.Item.Image.URL}
+	}
+	card.downloadTo(dir, links)
+	return nil
+}
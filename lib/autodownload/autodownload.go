@@ -0,0 +1,270 @@
+// Package autodownload parses the auto-download rules and keeps the history of the downloaded items.
+//
+// A rule is one line of conditions and a action:
+//
+//	feed="My Show" title~"1080p" -> download media to ~/Videos/show
+//
+// The conditions are FIELD=VALUE (equal, ignoring case), FIELD~REGEXP (the regexp matches, ignoring case),
+// FIELD!=VALUE and FIELD!~REGEXP, all of them must match. The values are quoted like in a shell.
+// The action downloads the media, link or image of the item, to the directory or the default download path.
+package autodownload
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+)
+
+// Fields are the fields of the item the conditions can match
+var Fields = []string{"feed", "feedurl", "title", "link", "author", "description"}
+
+// What is downloaded by the rule
+type What string
+
+const (
+	Media What = "media"
+	Link  What = "link"
+	Image What = "image"
+)
+
+type Rule struct {
+	// Line is the rule as it's written in the rules file
+	Line       string
+	Conditions []Condition
+	What       What
+	// Dir is where the files are downloaded, empty for the default download path
+	Dir string
+}
+
+type Condition struct {
+	Field  string
+	Negate bool
+	// Equal is compared to the field ignoring case, or the Regexp matches the field
+	Equal  string
+	Regexp *regexp.Regexp
+}
+
+// Match reports if the field values match the condition
+func (c Condition) Match(fields map[string]string) bool {
+	value := fields[c.Field]
+	var ok bool
+	if c.Regexp != nil {
+		ok = c.Regexp.MatchString(value)
+	} else {
+		ok = strings.EqualFold(value, c.Equal)
+	}
+	return ok != c.Negate
+}
+
+// Match reports if all the conditions match the field values
+func (r Rule) Match(fields map[string]string) bool {
+	for _, c := range r.Conditions {
+		if !c.Match(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse parses the rules, one rule on every line, lines starting with # are comments
+func Parse(r io.Reader) ([]Rule, error) {
+	var (
+		rules []Rule
+		errs  []error
+		n     int
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := ParseRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return rules, errors.Join(errs...)
+}
+
+// ParseRule parses one rule
+func ParseRule(line string) (Rule, error) {
+	conditions, action, ok := strings.Cut(line, "->")
+	if !ok {
+		return Rule{}, errors.New("missing -> before the action")
+	}
+	rule := Rule{Line: line}
+	words, err := cmdline.Split(conditions)
+	if err != nil {
+		return Rule{}, err
+	}
+	if len(words) == 0 {
+		return Rule{}, errors.New("no conditions")
+	}
+	for _, word := range words {
+		c, err := parseCondition(word)
+		if err != nil {
+			return Rule{}, err
+		}
+		rule.Conditions = append(rule.Conditions, c)
+	}
+	words, err = cmdline.Split(action)
+	if err != nil {
+		return Rule{}, err
+	}
+	if len(words) < 2 || words[0] != "download" {
+		return Rule{}, fmt.Errorf("unknown action: %s", strings.TrimSpace(action))
+	}
+	switch What(words[1]) {
+	case Media, Link, Image:
+		rule.What = What(words[1])
+	default:
+		return Rule{}, fmt.Errorf("unknown download %q, expected media, link or image", words[1])
+	}
+	switch {
+	case len(words) == 2:
+	case len(words) == 4 && words[2] == "to":
+		rule.Dir = words[3]
+	default:
+		return Rule{}, fmt.Errorf("unexpected %q after the download, expected to DIR", strings.Join(words[2:], " "))
+	}
+	return rule, nil
+}
+
+// conditionRe splits the condition to the field, operator and value
+var conditionRe = regexp.MustCompile(`^([a-z]+)(!=|!~|=|~)(.*)$`)
+
+func parseCondition(word string) (Condition, error) {
+	m := conditionRe.FindStringSubmatch(word)
+	if m == nil {
+		return Condition{}, fmt.Errorf("not a condition: %s", word)
+	}
+	c := Condition{Field: m[1], Negate: strings.HasPrefix(m[2], "!")}
+	known := false
+	for _, f := range Fields {
+		known = known || f == c.Field
+	}
+	if !known {
+		return Condition{}, fmt.Errorf("unknown field %q, expected one of %s", c.Field, strings.Join(Fields, ", "))
+	}
+	if !strings.HasSuffix(m[2], "~") {
+		c.Equal = m[3]
+		return c, nil
+	}
+	re, err := regexp.Compile("(?i)" + m[3])
+	if err != nil {
+		return Condition{}, fmt.Errorf("condition %s: %w", word, err)
+	}
+	c.Regexp = re
+	return c, nil
+}
+
+// maxHistory is the number of downloaded items remembered, the oldest are removed
+const maxHistory = 10000
+
+// History is the set of the automatically downloaded items, so every item is downloaded once,
+// it's kept in a json file
+type History struct {
+	path  string
+	mu    sync.Mutex
+	items map[string]time.Time
+	// saved is set, when the history file exists, it's false before the first run
+	saved bool
+}
+
+// LoadHistory loads the history from the file at path
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path, items: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	if err := json.Unmarshal(data, &h.items); err != nil {
+		return h, fmt.Errorf("parsing auto-download history: %w", err)
+	}
+	h.saved = true
+	return h, nil
+}
+
+// Saved reports if the history was saved before, it's false on the first run
+func (h *History) Saved() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.saved
+}
+
+// Has reports if the item was downloaded
+func (h *History) Has(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.items[id]
+	return ok
+}
+
+// Remember records the items as downloaded without saving the history, for the dry run
+func (h *History) Remember(ids ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		h.items[id] = now
+	}
+}
+
+// Add records the items as downloaded and saves the history
+func (h *History) Add(ids ...string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		h.items[id] = now
+	}
+	if len(h.items) > maxHistory {
+		ids := make([]string, 0, len(h.items))
+		for id := range h.items {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return h.items[ids[i]].Before(h.items[ids[j]])
+		})
+		for _, id := range ids[:len(ids)-maxHistory] {
+			delete(h.items, id)
+		}
+	}
+	data, err := json.Marshal(h.items)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return err
+	}
+	h.saved = true
+	return nil
+}
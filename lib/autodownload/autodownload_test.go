@@ -0,0 +1,190 @@
+package autodownload
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// condition is the expected condition, with the regexp as a string
+type condition struct {
+	field  string
+	negate bool
+	equal  string
+	regexp string
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		line       string
+		conditions []condition
+		what       What
+		dir        string
+		wantErr    bool
+	}{
+		{
+			line:       `feed="My Show" title~"1080p" -> download media to ~/Videos/show`,
+			conditions: []condition{{field: "feed", equal: "My Show"}, {field: "title", regexp: "(?i)1080p"}},
+			what:       Media,
+			dir:        "~/Videos/show",
+		},
+		{
+			line:       "feedurl~nyaa.si title!~batch -> download link",
+			conditions: []condition{{field: "feedurl", regexp: "(?i)nyaa.si"}, {field: "title", negate: true, regexp: "(?i)batch"}},
+			what:       Link,
+		},
+		{
+			line:       "author!='Jane Doe' -> download image to 'My Images'",
+			conditions: []condition{{field: "author", negate: true, equal: "Jane Doe"}},
+			what:       Image,
+			dir:        "My Images",
+		},
+		// the value can have a = or ~ in it
+		{
+			line:       "link=https://a.org/?a=b~c -> download link",
+			conditions: []condition{{field: "link", equal: "https://a.org/?a=b~c"}},
+			what:       Link,
+		},
+		{line: "feed=x download media", wantErr: true},
+		{line: "-> download media", wantErr: true},
+		{line: "feed -> download media", wantErr: true},
+		{line: "size=1 -> download media", wantErr: true},
+		{line: "Feed=x -> download media", wantErr: true},
+		{line: "title~( -> download media", wantErr: true},
+		{line: "feed='x -> download media", wantErr: true},
+		{line: "feed=x -> play media", wantErr: true},
+		{line: "feed=x -> download", wantErr: true},
+		{line: "feed=x -> download video", wantErr: true},
+		{line: "feed=x -> download media into dir", wantErr: true},
+		{line: "feed=x -> download media to", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRule(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got.Line != tt.line || got.What != tt.what || got.Dir != tt.dir {
+			t.Errorf("ParseRule(%q) = %q, %q, %q, want %q, %q", tt.line, got.Line, got.What, got.Dir, tt.what, tt.dir)
+		}
+		if len(got.Conditions) != len(tt.conditions) {
+			t.Errorf("ParseRule(%q) has %d conditions, want %d", tt.line, len(got.Conditions), len(tt.conditions))
+			continue
+		}
+		for i, c := range got.Conditions {
+			re := ""
+			if c.Regexp != nil {
+				re = c.Regexp.String()
+			}
+			if got := (condition{c.Field, c.Negate, c.Equal, re}); got != tt.conditions[i] {
+				t.Errorf("ParseRule(%q) condition %d = %+v, want %+v", tt.line, i, got, tt.conditions[i])
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(strings.NewReader(`# my shows
+feed="My Show" -> download media
+
+  title~1080p -> download link
+bad rule
+feed=x -> download nothing
+`))
+	if len(rules) != 2 || rules[0].What != Media || rules[1].What != Link {
+		t.Errorf("Parse() = %+v, want the media and the link rule", rules)
+	}
+	// the errors have the line numbers, the valid rules are still returned
+	if err == nil || !strings.Contains(err.Error(), "line 5:") || !strings.Contains(err.Error(), "line 6:") {
+		t.Errorf("Parse() error = %v, want the errors of the lines 5 and 6", err)
+	}
+	if rules, err := Parse(strings.NewReader("\n# only a comment\n")); err != nil || len(rules) != 0 {
+		t.Errorf("Parse() of no rules = %v, %v", rules, err)
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	fields := map[string]string{
+		"feed":  "My Show",
+		"title": "Episode 12 [1080p]",
+		"link":  "https://example.com/12",
+	}
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{`feed="my show" -> download media`, true},
+		{`feed="My" -> download media`, false},
+		{`feed!="Other Show" -> download media`, true},
+		{`title~1080P -> download media`, true},
+		// the backslashes are kept in single quotes
+		{`title~'^episode\s+\d+' -> download media`, true},
+		{`title!~1080p -> download media`, false},
+		// all the conditions must match
+		{`feed="My Show" title~720p -> download media`, false},
+		{`feed="My Show" title~1080p link~example -> download media`, true},
+		// the missing fields are empty
+		{`author="" -> download media`, true},
+		{`author~. -> download media`, false},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.line)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %s", tt.line, err)
+		}
+		if got := rule.Match(fields); got != tt.want {
+			t.Errorf("%q Match() = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "autodownload.json")
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Saved() || h.Has("a") {
+		t.Errorf("new history: Saved() = %v, Has(a) = %v, want false", h.Saved(), h.Has("a"))
+	}
+	// the remembered items aren't saved
+	h.Remember("x")
+	if !h.Has("x") || h.Saved() {
+		t.Errorf("Remember: Has(x) = %v, Saved() = %v, want true, false", h.Has("x"), h.Saved())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Remember wrote the history file: %v", err)
+	}
+	if err := h.Add("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Add("c"); err != nil {
+		t.Fatal(err)
+	}
+	if !h.Saved() {
+		t.Error("Saved() after Add = false")
+	}
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Saved() {
+		t.Error("loaded history: Saved() = false")
+	}
+	for id, want := range map[string]bool{"a": true, "b": true, "c": true, "d": false} {
+		if got := loaded.Has(id); got != want {
+			t.Errorf("loaded history: Has(%q) = %v, want %v", id, got, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := LoadHistory(path); err == nil || h.Saved() {
+		t.Errorf("corrupted history: error = %v, Saved() = %v, want a error and not saved", err, h.Saved())
+	}
+}
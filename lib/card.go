@@ -2,21 +2,22 @@ package lib
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"image"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/user"
-	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"git.sr.ht/~ghost08/photon/imgproc"
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
 	"git.sr.ht/~ghost08/photon/lib/events"
+	"git.sr.ht/~ghost08/photon/lib/history"
 	"git.sr.ht/~ghost08/photon/lib/media"
-	"github.com/kennygrant/sanitize"
 	"github.com/mmcdole/gofeed"
 	"github.com/skratchdot/open-golang/open"
 )
@@ -27,10 +28,26 @@ type Card struct {
 	ItemImage  imgproc.ImageResizer
 	Feed       *gofeed.Feed
 	FeedImage  imgproc.ImageResizer
-	Article    *Article
-	Media      *media.Media
 	Foreground int
 	Background int
+	// offlineReady is set when the article and top image are in the offline store,
+	// it's set by the offline sync goroutines and read by the ui
+	offlineReady atomic.Bool
+	// FeedInput is the feed url or command from the feed list, the card was loaded from
+	FeedInput string
+	// comments are published by the download goroutine under the commentsMu, see Comments
+	comments   *Comments
+	commentsMu sync.Mutex
+	// article is published by loadArticle under the articleMu, it's loaded from the ui and the background exports
+	article   *Article
+	articleMu sync.Mutex
+	// inLinkHandler is set while a lua link handler opens the card's link, see OpenLink
+	inLinkHandler atomic.Bool
+	// media is published by GetMedia under the mediaMu, it's read by the ui,
+	// mediaLoad lets one goroutine extract the media at a time
+	media     *media.Media
+	mediaMu   sync.Mutex
+	mediaLoad sync.Mutex
 }
 
 type Cards []*Card
@@ -47,6 +64,39 @@ func (cards Cards) Swap(i, k int) {
 	cards[i], cards[k] = cards[k], cards[i]
 }
 
+// matches reports if the lower case query is in the card's title, description,
+// feed title or author name
+func (card *Card) matches(query string) bool {
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(card.Item.Title), query) ||
+		strings.Contains(strings.ToLower(card.Item.Description), query) ||
+		card.Feed != nil && strings.Contains(strings.ToLower(card.Feed.Title), query) ||
+		card.Item.Author != nil && strings.Contains(strings.ToLower(card.Item.Author.Name), query)
+}
+
+// CommandVars returns the values of the command template placeholders for the card
+func (card *Card) CommandVars() cmdline.Vars {
+	vars := cmdline.Vars{
+		"url":   card.Item.Link,
+		"title": card.Item.Title,
+	}
+	if card.Feed != nil {
+		vars["feed"] = card.Feed.Title
+	}
+	if m := card.LoadedMedia(); m != nil && len(m.Links) > 0 {
+		vars["media"] = m.Links[0]
+		if len(m.Links) > 1 {
+			vars["audio"] = m.Links[1]
+		}
+	}
+	return vars
+}
+
+// OfflineReady reports if the article and top image are in the offline store
+func (card *Card) OfflineReady() bool {
+	return card.offlineReady.Load()
+}
+
 func (card *Card) SaveImage() func(image.Image) {
 	return func(img image.Image) {
 		card.ItemImage = imgproc.NewImageResizer(img)
@@ -58,24 +108,19 @@ func (card *Card) OpenArticle(ctx context.Context) {
 	if card == nil {
 		return
 	}
-	if card.Article == nil {
-		article, err := newArticle(ctx, card, card.photon.httpClient)
-		if err != nil {
-			log.Println("ERROR: scraping link:", err)
-			return
-		}
-		card.Article = article
-		if len(card.Article.TextContent) < len(card.Item.Description) {
-			card.Article.TextContent = card.Item.Description
-		}
+	article, err := card.loadArticle(ctx)
+	if err != nil {
+		log.Println("ERROR: scraping link:", err)
+		return
 	}
-	card.photon.OpenedArticle = card.Article
+	card.addHistory(history.KindArticle)
+	card.photon.OpenedArticle = article
 	card.photon.cb.ArticleChanged(card.photon.OpenedArticle)
 	events.Emit(&events.ArticleOpened{
 		Link: card.Item.Link,
 		Card: newCardFunc(card),
 	})
-	if card.photon.OpenedArticle.Image != "" {
+	if card.photon.OpenedArticle.Image != "" && card.photon.OpenedArticle.TopImage == nil {
 		card.photon.ImgDownloader.Download(
 			card.photon.OpenedArticle.Image,
 			func(i any) {
@@ -90,28 +135,168 @@ func (card *Card) OpenArticle(ctx context.Context) {
 	card.photon.cb.Redraw()
 }
 
+// loadedArticle returns the article loaded by loadArticle, nil if it wasn't loaded yet
+func (card *Card) loadedArticle() *Article {
+	card.articleMu.Lock()
+	defer card.articleMu.Unlock()
+	return card.article
+}
+
+// loadArticle returns the card's article, it's fetched without holding the lock
+// and kept for the next loads, the first loaded article wins
+func (card *Card) loadArticle(ctx context.Context) (*Article, error) {
+	if article := card.loadedArticle(); article != nil {
+		return article, nil
+	}
+	article, err := card.fetchArticle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	card.articleMu.Lock()
+	defer card.articleMu.Unlock()
+	if card.article == nil {
+		card.article = article
+	}
+	return card.article, nil
+}
+
+// fetchArticle loads the card's article from the offline store, or scrapes it
+func (card *Card) fetchArticle(ctx context.Context) (*Article, error) {
+	if card.OfflineReady() {
+		article, err := card.loadOfflineArticle()
+		if err == nil {
+			return article, nil
+		}
+		log.Println("ERROR: loading offline article:", err)
+	}
+	article, err := newArticle(ctx, card, card.photon.httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if len(article.TextContent) < len(card.Item.Description) {
+		article.TextContent = card.Item.Description
+	}
+	return article, nil
+}
+
+// LoadedMedia returns a copy of the extracted media, nil if it wasn't extracted yet
+func (card *Card) LoadedMedia() *media.Media {
+	card.mediaMu.Lock()
+	defer card.mediaMu.Unlock()
+	if card.media == nil {
+		return nil
+	}
+	m := *card.media
+	return &m
+}
+
+// GetMedia returns a copy of the card's media, it's extracted when it wasn't extracted yet
+// or it's expired, the concurrent callers wait for the one extraction
 func (card *Card) GetMedia() (*media.Media, error) {
 	if card == nil {
 		return nil, nil //nolint:nilnil // it doesn't matter if it is nil
 	}
-	if card.Media == nil || len(card.Media.Links) == 0 {
-		m, err := card.photon.mediaExtractor.NewMedia(context.TODO(), card.Item.Link)
+	card.mediaLoad.Lock()
+	defer card.mediaLoad.Unlock()
+	if old := card.LoadedMedia(); old == nil || len(old.Links) == 0 || old.Expired() {
+		var m *media.Media
+		// podcast episodes are played from the enclosure, without the extractor
+		if e, ok := card.AudioEnclosure(); ok {
+			m = card.photon.mediaExtractor.DirectMedia(e.URL, enclosureType(e))
+		} else if t, ok := card.Torrent(); ok {
+			// torrents are handed to the torrent command, by the magnet or the .torrent link
+			m = card.photon.mediaExtractor.DirectMedia(t.link(card.photon.torrentLink))
+		} else {
+			var err error
+			m, err = card.photon.mediaExtractor.NewMedia(context.TODO(), card.Item.Link)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// the picked format is kept, when the expired media is extracted again
+		if old != nil && old.Format != "" {
+			format, _, _ := strings.Cut(old.Format, "+")
+			if err := m.SelectFormat(format); err != nil {
+				log.Println("ERROR: selecting format:", err)
+			}
+		}
+		m.Title = card.Item.Title
+		m.ItemLink = card.Item.Link
+		if card.Feed != nil {
+			m.Feed = card.Feed.Title
+		}
+		card.mediaMu.Lock()
+		card.media = m
+		card.mediaMu.Unlock()
+	}
+	return card.LoadedMedia(), nil
+}
+
+// SelectFormat selects the format of the extracted media, see media.SelectFormat,
+// the media is copied, so the copies returned before don't change
+func (card *Card) SelectFormat(id string) error {
+	card.mediaMu.Lock()
+	defer card.mediaMu.Unlock()
+	if card.media == nil {
+		return errors.New("media isn't extracted")
+	}
+	m := *card.media
+	if err := m.SelectFormat(id); err != nil {
+		return err
+	}
+	card.media = &m
+	return nil
+}
+
+// LoadMedia extracts the media in the background and calls done with it
+func (card *Card) LoadMedia(done func(*media.Media)) {
+	if card == nil {
+		return
+	}
+	card.photon.SetStatusWithSpinner(fmt.Sprintf("Extracting media of %s", card.Item.Title))
+	go func() {
+		m, err := card.GetMedia()
 		if err != nil {
-			return nil, err
+			log.Println("ERROR: extracting media link:", err)
+			card.photon.StatusWithTimeout(
+				fmt.Sprintf("ERROR: extracting media link: %s", err),
+				time.Second*3,
+			)
+			return
 		}
-		card.Media = m
+		card.photon.SetStatus("")
+		done(m)
+		card.photon.cb.Redraw()
+	}()
+}
+
+// Duration returns the duration of the item's media, from the extractor, the itunes:duration
+// or the last playback, 0 if it isn't known
+func (card *Card) Duration() time.Duration {
+	if m := card.LoadedMedia(); m != nil && m.Duration > 0 {
+		return m.Duration
+	}
+	if d := card.itunesDuration(); d > 0 {
+		return d
 	}
-	return card.Media, nil
+	_, d := card.MediaProgress()
+	return d
 }
 
 func (card *Card) RunMedia() {
 	if card == nil {
 		return
 	}
+	card.runMedia(card.GetMedia)
+}
+
+// runMedia plays the media returned by getMedia in the background
+func (card *Card) runMedia(getMedia func() (*media.Media, error)) {
 	events.Emit(&events.RunMediaStart{
 		Link: card.Item.Link,
 		Card: newCardFunc(card),
 	})
+	card.addHistory(history.KindMedia)
 	card.photon.SetStatusWithSpinner(fmt.Sprintf("Play \u25B6 %s", card.Item.Title))
 	go func() {
 		var err error
@@ -127,7 +312,8 @@ func (card *Card) RunMedia() {
 				)
 			}
 		}()
-		_, err = card.GetMedia()
+		var m *media.Media
+		m, err = getMedia()
 		if err != nil {
 			log.Println("ERROR: extracting media link:", err)
 			card.photon.StatusWithTimeout(
@@ -136,7 +322,8 @@ func (card *Card) RunMedia() {
 			)
 			return
 		}
-		card.Media.Run(context.TODO())
+		m.Start = card.resumePosition()
+		m.Run(context.TODO())
 	}()
 }
 
@@ -144,21 +331,22 @@ func (card *Card) DownloadMedia() {
 	if card == nil {
 		return
 	}
+	if link, ok := card.torrentFile(); ok {
+		card.download([]string{link})
+		return
+	}
 	go func() {
 		log.Println("INFO: downloading media for:", card.Item.Link)
 		m, err := card.GetMedia()
 		if err != nil {
 			log.Println("ERROR: extracting media link:", err)
+			card.photon.StatusWithTimeout(
+				fmt.Sprintf("ERROR: extracting media link: %s", err),
+				time.Second*3,
+			)
 			return
 		}
-		if err := card.downloadLinks(
-			context.TODO(),
-			card.Item.Title,
-			m.Links,
-		); err != nil {
-			log.Println("ERROR: downloading media:", err)
-			return
-		}
+		card.download(m.Links)
 	}()
 }
 
@@ -166,87 +354,41 @@ func (card *Card) DownloadLink() {
 	if card == nil {
 		return
 	}
-	go func() {
-		if err := card.downloadLinks(
-			context.TODO(),
-			card.Item.Title,
-			[]string{card.Item.Link},
-		); err != nil {
-			log.Println("ERROR: downloading link:", err)
-			return
-		}
-	}()
+	card.download([]string{card.Item.Link})
 }
 
 func (card *Card) DownloadImage() {
 	if card == nil || card.Item == nil || card.Item.Image == nil {
 		return
 	}
-	go func() {
-		if err := card.downloadLinks(
-			context.TODO(),
-			card.Item.Title,
-			[]string{card.Item.Image.URL},
-		); err != nil {
-			log.Println("ERROR: downloading image:", err)
-			return
-		}
-	}()
+	card.download([]string{card.Item.Image.URL})
 }
 
-func (card *Card) downloadLinks(ctx context.Context, name string, links []string) error {
-	// get download path
-	downloadPath := card.photon.downloadPath
-	if strings.Contains(downloadPath, "$HOME") {
-		usr, err := user.Current()
-		if err != nil {
-			return err
-		}
-		downloadPath = strings.ReplaceAll(downloadPath, "$HOME", usr.HomeDir)
+// downloadDir returns the download path with $HOME expanded, it's created if it doesn't exist
+func (p *Photon) downloadDir() (string, error) {
+	downloadPath, err := expandHome(p.downloadPath)
+	if err != nil {
+		return "", err
 	}
-	// create download path
-	if _, err := os.Stat(downloadPath); os.IsNotExist(err) {
-		if err := os.MkdirAll(downloadPath, 0x755); err != nil {
-			return err
-		}
+	if err := os.MkdirAll(downloadPath, 0o755); err != nil {
+		return "", err
 	}
-	for _, link := range links {
-		// get response
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
-		if err != nil {
-			return err
-		}
-		client := card.photon.httpClient
-		resp, err := client.Do(req)
-		if err != nil {
-			return err
-		}
-		var bodyReader io.Reader = resp.Body
-		contentType := resp.Header.Get("Content-Type")
-		if contentType == "" {
-			contentType, bodyReader, err = recycleReader(resp.Body)
-		}
-		if err != nil {
-			return err
-		}
-		exts := extensionByType(contentType)
-		if len(exts) > 0 {
-			name += "." + exts[0]
-		}
-		// write data to file
-		f, err := os.Create(filepath.Join(downloadPath, sanitize.Name(name)))
-		if err != nil {
-			return err
-		}
-		if _, err := io.Copy(f, bodyReader); err != nil {
-			return err
-		}
-		if err := f.Close(); err != nil {
-			return err
-		}
-		log.Printf("INFO: downloaded link %s to %s", link, filepath.Join(downloadPath, name))
+	return downloadPath, nil
+}
+
+// expandHome replaces $HOME and the leading ~ with the home directory
+func expandHome(path string) (string, error) {
+	if !strings.Contains(path, "$HOME") && path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
 	}
-	return nil
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = usr.HomeDir + path[1:]
+	}
+	return strings.ReplaceAll(path, "$HOME", usr.HomeDir), nil
 }
 
 func (card *Card) OpenBrowser() error {
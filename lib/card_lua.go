@@ -21,16 +21,63 @@ func (p *Photon) cardLoader(L *lua.LState) int {
 		"published":   cardItemPublished,
 		"feed":        cardFeed,
 		"getMedia":    getMedia,
+		"episode": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			L.Push(lua.LString(card.Episode()))
+			return 1
+		},
+		"torrent": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			t, ok := card.Torrent()
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			tbl := L.NewTable()
+			tbl.RawSetString("seeders", lua.LNumber(t.Seeders))
+			tbl.RawSetString("leechers", lua.LNumber(t.Leechers))
+			tbl.RawSetString("size", lua.LNumber(t.Size))
+			tbl.RawSetString("infoHash", lua.LString(t.InfoHash))
+			tbl.RawSetString("magnet", lua.LString(t.Magnet))
+			tbl.RawSetString("url", lua.LString(t.URL))
+			L.Push(tbl)
+			return 1
+		},
+		"offlineReady": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			L.Push(lua.LBool(card.OfflineReady()))
+			return 1
+		},
+		"commentsCount": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			count, ok := card.CommentsCount()
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(lua.LNumber(count))
+			return 1
+		},
 		"runMedia": func(L *lua.LState) int {
 			card := checkCard(L, 1)
 			card.RunMedia()
 			return 0
 		},
+		"enqueue": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			card.Enqueue()
+			return 0
+		},
 		"openBrowser": func(L *lua.LState) int {
 			card := checkCard(L, 1)
 			_ = card.OpenBrowser()
 			return 0
 		},
+		"openLink": func(L *lua.LState) int {
+			card := checkCard(L, 1)
+			_ = card.OpenLink(L.OptString(2, LinkBrowser))
+			return 0
+		},
 		"openArticle": func(L *lua.LState) int {
 			card := checkCard(L, 1)
 			card.OpenArticle(context.Background())
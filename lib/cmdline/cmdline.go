@@ -0,0 +1,201 @@
+// Package cmdline builds the commands photon runs from the user's command templates.
+//
+// The template is split into arguments like a posix shell does it (with single quotes,
+// double quotes and backslash escapes), then the placeholders ({url}, {media}, {title},
+// {feed}, {file}, {audio}, {format}, {start}) are substituted in every argument. A value is never split
+// into more arguments, so titles and links with spaces or quotes are passed as they are.
+// {name:q} substitutes the value quoted for a shell, for templates like sh -c '... {title:q}'.
+package cmdline
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Vars are the values of the placeholders, by the placeholder name
+type Vars map[string]string
+
+// Legacy maps the old single character placeholders (% and $) to the template they stand for,
+// they are used only if the template has no named placeholder, and only as a whole argument,
+// so a % in a argument (date +%s, %5B in a url) is kept
+type Legacy map[rune]string
+
+// Template is a parsed command template
+type Template struct {
+	args []string
+}
+
+const names = `(url|media|title|feed|file|audio|format|start)`
+
+var placeholderRe = regexp.MustCompile(`\{` + names + `(:q)?\}`)
+
+// Parse splits the command template into arguments
+func Parse(command string, legacy Legacy) (*Template, error) {
+	args, err := Split(command)
+	if err != nil {
+		return nil, fmt.Errorf("parsing command `%s`: %w", command, err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("parsing command `%s`: empty command", command)
+	}
+	if len(legacy) > 0 && !placeholderRe.MatchString(command) {
+		args, err = replaceLegacy(args, legacy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing command `%s`: %w", command, err)
+		}
+	}
+	return &Template{args: args}, nil
+}
+
+// replaceLegacy replaces the arguments, that are only a legacy placeholder, with the arguments
+// of the template it stands for
+func replaceLegacy(args []string, legacy Legacy) ([]string, error) {
+	replaced := make([]string, 0, len(args))
+	for _, arg := range args {
+		var tmpl string
+		if r := []rune(arg); len(r) == 1 {
+			tmpl = legacy[r[0]]
+		}
+		if tmpl == "" {
+			replaced = append(replaced, arg)
+			continue
+		}
+		tmplArgs, err := Split(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, tmplArgs...)
+	}
+	return replaced, nil
+}
+
+// Uses reports if the template has the placeholder
+func (t *Template) Uses(name string) bool {
+	for _, arg := range t.args {
+		for _, m := range placeholderRe.FindAllStringSubmatch(arg, -1) {
+			if m[1] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// optionalArgRe matches the arguments that are left out if the placeholder has no value,
+// a lone placeholder or a option with the placeholder as value (--audio-file={audio})
+var optionalArgRe = regexp.MustCompile(`^(-[^=]*=)?\{` + names + `\}$`)
+
+// Args returns the arguments with the placeholders substituted
+func (t *Template) Args(vars Vars) []string {
+	args := make([]string, 0, len(t.args))
+	for _, arg := range t.args {
+		if m := optionalArgRe.FindStringSubmatch(arg); m != nil && vars[m[2]] == "" {
+			continue
+		}
+		args = append(args, placeholderRe.ReplaceAllStringFunc(arg, func(p string) string {
+			m := placeholderRe.FindStringSubmatch(p)
+			if m[2] != "" {
+				return Quote(vars[m[1]])
+			}
+			return vars[m[1]]
+		}))
+	}
+	return args
+}
+
+// Command returns the command with the placeholders substituted
+func (t *Template) Command(vars Vars) (*exec.Cmd, error) {
+	args := t.Args(vars)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+	return exec.Command(args[0], args[1:]...), nil //nolint:gosec // we trust the user
+}
+
+// String returns the template arguments quoted for a shell
+func (t *Template) String() string {
+	quoted := make([]string, len(t.args))
+	for i, arg := range t.args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Command parses the template and returns the command with the placeholders substituted
+func Command(command string, legacy Legacy, vars Vars) (*exec.Cmd, error) {
+	t, err := Parse(command, legacy)
+	if err != nil {
+		return nil, err
+	}
+	return t.Command(vars)
+}
+
+// Split splits s into words like a posix shell, without any expansions
+func Split(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escaped bool
+		quote   rune
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			// in double quotes the backslash escapes only some characters
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			if r != '\n' {
+				word.WriteRune(r)
+			}
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	switch {
+	case escaped:
+		return nil, errors.New("unfinished escape")
+	case quote != 0:
+		return nil, fmt.Errorf("unclosed quote %c", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// Quote quotes s for a posix shell
+func Quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`!&|;<>()[]{}*?~#") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
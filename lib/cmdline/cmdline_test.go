@@ -0,0 +1,149 @@
+package cmdline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "  mpv   %  ", want: []string{"mpv", "%"}},
+		{in: "mpv --title='a b' {url}", want: []string{"mpv", "--title=a b", "{url}"}},
+		{in: `sh -c "echo \"hi\" \$HOME"`, want: []string{"sh", "-c", `echo "hi" $HOME`}},
+		{in: `echo "a\nb"`, want: []string{"echo", `a\nb`}},
+		{in: `echo 'a\nb'`, want: []string{"echo", `a\nb`}},
+		{in: `echo a\ b`, want: []string{"echo", "a b"}},
+		{in: "echo ''", want: []string{"echo", ""}},
+		{in: "echo a\\\nb", want: []string{"echo", "ab"}},
+		{in: "echo 'a", wantErr: true},
+		{in: `echo "a`, wantErr: true},
+		{in: `echo a\`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Split(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "''"},
+		{"plain", "plain"},
+		{"https://example.com/a", "https://example.com/a"},
+		{"a?b", "'a?b'"},
+		{"two words", "'two words'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+		{"a;rm -rf", "'a;rm -rf'"},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// the quoted value is split back to the value
+		if words, err := Split(Quote(tt.in)); err != nil || len(words) != 1 || words[0] != tt.in {
+			t.Errorf("Split(Quote(%q)) = %q, %v", tt.in, words, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	mediaLegacy := Legacy{'%': "{media} --audio-file={audio}", '$': "{url}"}
+	tests := []struct {
+		command string
+		legacy  Legacy
+		want    []string
+		wantErr bool
+	}{
+		{command: "mpv {url}", legacy: mediaLegacy, want: []string{"mpv", "{url}"}},
+		{command: "mpv %", legacy: mediaLegacy, want: []string{"mpv", "{media}", "--audio-file={audio}"}},
+		{command: "mpv '%'", legacy: mediaLegacy, want: []string{"mpv", "{media}", "--audio-file={audio}"}},
+		{command: "imv $", legacy: mediaLegacy, want: []string{"imv", "{url}"}},
+		// a % in a argument isn't a placeholder
+		{command: "date +%s", legacy: mediaLegacy, want: []string{"date", "+%s"}},
+		{command: "curl https://a.org/%5Bx%5D", legacy: mediaLegacy, want: []string{"curl", "https://a.org/%5Bx%5D"}},
+		// the legacy placeholders aren't used with the named ones
+		{command: "mpv {media} %", legacy: mediaLegacy, want: []string{"mpv", "{media}", "%"}},
+		{command: "mpv %", legacy: nil, want: []string{"mpv", "%"}},
+		{command: "", wantErr: true},
+		{command: "mpv 'a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.command, tt.legacy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !reflect.DeepEqual(got.args, tt.want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.command, got.args, tt.want)
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	tmpl, err := Parse("mpv --start={start} {file:q}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"start": true, "file": true, "url": false} {
+		if got := tmpl.Uses(name); got != want {
+			t.Errorf("Uses(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	vars := Vars{
+		"url":   "https://example.com/watch?v=1",
+		"media": "https://cdn.example.com/v.mp4",
+		"title": "it's a title",
+	}
+	tests := []struct {
+		command string
+		legacy  Legacy
+		want    []string
+	}{
+		{command: "mpv {url}", want: []string{"mpv", "https://example.com/watch?v=1"}},
+		// a value with spaces is one argument
+		{command: "notify-send {title}", want: []string{"notify-send", "it's a title"}},
+		{command: "sh -c 'notify-send {title:q}'", want: []string{"sh", "-c", `notify-send 'it'\''s a title'`}},
+		// a argument with a placeholder without value is left out
+		{command: "mpv --audio-file={audio} --start={start} {media}", want: []string{"mpv", "https://cdn.example.com/v.mp4"}},
+		{command: "mpv {audio}", want: []string{"mpv"}},
+		{command: "echo x{audio}y", want: []string{"echo", "xy"}},
+		{
+			command: "mpv %",
+			legacy:  Legacy{'%': "{media} --audio-file={audio}"},
+			want:    []string{"mpv", "https://cdn.example.com/v.mp4"},
+		},
+	}
+	for _, tt := range tests {
+		cmd, err := Command(tt.command, tt.legacy, vars)
+		if err != nil {
+			t.Errorf("Command(%q) error = %v", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(cmd.Args, tt.want) {
+			t.Errorf("Command(%q) = %q, want %q", tt.command, cmd.Args, tt.want)
+		}
+	}
+	if _, err := Command("{audio}", nil, vars); err == nil {
+		t.Error("Command with no arguments left: want error")
+	}
+}
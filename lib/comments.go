@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// keys of the item.Custom map with the comment info of the item
+const (
+	customCommentsFeed  = "commentsFeed"
+	customCommentsLink  = "commentsLink"
+	customCommentsCount = "commentsCount"
+)
+
+// Comment is a item of the card's comment feed
+type Comment struct {
+	ID        string
+	Author    string
+	Published time.Time
+	// Content is the html content of the comment
+	Content string
+	Replies []*Comment
+	// inReplyTo is the ID of the parent comment
+	inReplyTo string
+}
+
+// Comments is the comment thread of the card, it's downloaded in the background
+type Comments struct {
+	Thread  []*Comment
+	Loading bool
+	Err     error
+}
+
+// CommentsFeed returns the link to the comment feed of the item (wfw:commentRss, atom replies link)
+func (card *Card) CommentsFeed() string {
+	return card.Item.Custom[customCommentsFeed]
+}
+
+// CommentsCount returns the number of comments of the item (slash:comments, thr:total)
+func (card *Card) CommentsCount() (int, bool) {
+	count, ok := card.Item.Custom[customCommentsCount]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(count)
+	return n, err == nil
+}
+
+// Comments returns the comment thread of the card, nil if it wasn't loaded
+func (card *Card) Comments() *Comments {
+	card.commentsMu.Lock()
+	defer card.commentsMu.Unlock()
+	return card.comments
+}
+
+// setComments publishes the comment thread, the ui gets it by Comments
+func (card *Card) setComments(c *Comments) {
+	card.commentsMu.Lock()
+	defer card.commentsMu.Unlock()
+	card.comments = c
+}
+
+// LoadComments starts downloading the comment feed, if it isn't already downloaded,
+// Redraw is called when the comments are loaded
+func (card *Card) LoadComments() {
+	if card.Comments() != nil {
+		return
+	}
+	link := card.CommentsFeed()
+	if link == "" {
+		card.setComments(&Comments{})
+		return
+	}
+	card.setComments(&Comments{Loading: true})
+	go func() {
+		thread, err := card.photon.downloadComments(link)
+		card.setComments(&Comments{Thread: thread, Err: err})
+		card.photon.cb.Redraw()
+	}()
+}
+
+func (p *Photon) downloadComments(link string) ([]*Comment, error) {
+	f, err := p.newFeedParser().ParseURL(link)
+	if err != nil {
+		return nil, err
+	}
+	comments := make([]*Comment, len(f.Items))
+	byID := make(map[string]*Comment, len(f.Items))
+	for i, item := range f.Items {
+		c := &Comment{
+			ID:      item.GUID,
+			Author:  commentAuthor(item),
+			Content: item.Content,
+		}
+		if c.Content == "" {
+			c.Content = item.Description
+		}
+		if item.PublishedParsed != nil {
+			c.Published = *item.PublishedParsed
+		}
+		if ref, ok := getExt(func() string { return item.Extensions["thr"]["in-reply-to"][0].Attrs["ref"] }); ok {
+			c.inReplyTo = ref
+		}
+		comments[i] = c
+		if c.ID != "" {
+			byID[c.ID] = c
+		}
+	}
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Published.Before(comments[j].Published)
+	})
+	var thread []*Comment
+	for _, c := range comments {
+		if parent, ok := byID[c.inReplyTo]; ok && parent != c {
+			parent.Replies = append(parent.Replies, c)
+			continue
+		}
+		thread = append(thread, c)
+	}
+	return thread, nil
+}
+
+func commentAuthor(item *gofeed.Item) string {
+	switch {
+	case item.Author != nil && item.Author.Name != "":
+		return item.Author.Name
+	case len(item.Authors) > 0 && item.Authors[0].Name != "":
+		return item.Authors[0].Name
+	}
+	// wordpress comment feeds have titles like "By: author"
+	if _, author, ok := strings.Cut(item.Title, ": "); ok {
+		return author
+	}
+	return item.Title
+}
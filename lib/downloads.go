@@ -0,0 +1,277 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/downloads"
+	"git.sr.ht/~ghost08/photon/lib/events"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// WithDownloadWorkers sets the number of links downloaded at the same time
+func WithDownloadWorkers(workers int) Option {
+	return func(p *Photon) {
+		p.downloadWorkers = workers
+	}
+}
+
+// WithDownloadName sets the template of the downloaded file names, see downloadName
+func WithDownloadName(template string) Option {
+	return func(p *Photon) {
+		p.downloadName = template
+	}
+}
+
+// WithDownloadSidecars sets the metadata files written next to the downloaded files (json, nfo)
+func WithDownloadSidecars(kinds []string) Option {
+	return func(p *Photon) {
+		p.downloadSidecars = kinds
+	}
+}
+
+// Downloads returns the download manager
+func (p *Photon) Downloads() *downloads.Manager {
+	return p.downloads
+}
+
+// download queues the links in the download manager, they are saved in the feed's download
+// directory or the download path, named by the download name template
+func (card *Card) download(links []string) {
+	dir, err := card.downloadDir()
+	if err != nil {
+		card.photon.StatusWithTimeout(fmt.Sprintf("ERROR: download path: %s", err), time.Second*3)
+		return
+	}
+	card.downloadTo(dir, links)
+	card.photon.StatusWithTimeout(fmt.Sprintf("Downloading %s", card.Item.Title), time.Second*3)
+}
+
+// downloadTo queues the links in the download manager, they are saved in the dir
+func (card *Card) downloadTo(dir string, links []string) {
+	d := downloads.Download{
+		Title:       card.Item.Title,
+		ItemLink:    card.Item.Link,
+		Description: card.Item.Custom["simpleContent"],
+		Dir:         dir,
+		Name:        card.downloadName(card.photon.downloadName),
+	}
+	if d.Description == "" {
+		d.Description = card.Item.Description
+	}
+	if card.Feed != nil {
+		d.Feed = card.Feed.Title
+	}
+	if card.Item.Author != nil {
+		d.Author = card.Item.Author.Name
+	}
+	if card.Item.PublishedParsed != nil {
+		d.Published = *card.Item.PublishedParsed
+	}
+	for i, link := range links {
+		d.URL, d.Part = link, i
+		card.photon.downloads.Add(d)
+	}
+}
+
+// downloadDir returns the download directory of the card's feed (the downloads option in the
+// feed list), or the download path
+func (card *Card) downloadDir() (string, error) {
+	o, ok := card.photon.feedOptions[card.FeedInput]
+	if !ok || o.DownloadDir == "" {
+		return card.photon.downloadDir()
+	}
+	dir, err := expandHome(o.DownloadDir)
+	if err != nil {
+		return "", err
+	}
+	return dir, os.MkdirAll(dir, 0o755)
+}
+
+// downloadNameRe matches the placeholders of the download name template, {date} can have
+// a go time layout ({date:2006-01-02})
+var downloadNameRe = regexp.MustCompile(`\{(feed|title|author|date)(?::([^}]*))?\}`)
+
+// downloadName returns the file name of the download by the template, the values are made safe
+// for file names, the slashes in the template create directories, {ext} is left for the download manager
+func (card *Card) downloadName(template string) string {
+	if template == "" {
+		template = "{title}.{ext}"
+	}
+	name := downloadNameRe.ReplaceAllStringFunc(template, func(placeholder string) string {
+		m := downloadNameRe.FindStringSubmatch(placeholder)
+		var value string
+		switch m[1] {
+		case "feed":
+			if card.Feed != nil {
+				value = card.Feed.Title
+			}
+		case "title":
+			value = card.Item.Title
+		case "author":
+			if card.Item.Author != nil {
+				value = card.Item.Author.Name
+			}
+		case "date":
+			layout := m[2]
+			if layout == "" {
+				layout = "2006-01-02"
+			}
+			if card.Item.PublishedParsed != nil {
+				value = card.Item.PublishedParsed.Format(layout)
+			}
+		}
+		return safeFileName(value)
+	})
+	// the name can't leave the download directory
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "." || part == ".." {
+			continue
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "download.{ext}"
+	}
+	return filepath.Join(parts...)
+}
+
+// safeFileName replaces the characters, that can't be in file names,
+// and shortens the name to a length all file systems support
+func safeFileName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < ' ', strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.Trim(s, " .")
+	const maxLength = 150
+	if len(s) > maxLength {
+		s = strings.ToValidUTF8(s[:maxLength], "")
+	}
+	return s
+}
+
+// DownloadCard returns the card the download was started from, nil if it isn't loaded
+func (p *Photon) DownloadCard(d downloads.Download) *Card {
+	for _, card := range p.Cards {
+		if card.Item.Link == d.ItemLink {
+			return card
+		}
+	}
+	return nil
+}
+
+// onDownloadEvent redraws the downloads and emits the finished and failed events for the plugins
+func (p *Photon) onDownloadEvent(t downloads.EventType, d downloads.Download) {
+	switch t {
+	case downloads.Progress:
+		// the progress is shown only in the downloads view
+		if p.cb.State() != states.Downloads {
+			return
+		}
+	case downloads.Finished:
+		p.writeSidecars(d)
+		p.autoDownloadFinished(d)
+		events.Emit(&events.DownloadFinished{
+			Link: d.URL,
+			Path: d.Path,
+			Card: p.downloadCardFunc(d),
+		})
+		p.StatusWithTimeout(fmt.Sprintf("Downloaded %s", d.Title), time.Second*3)
+	case downloads.FailedEvent:
+		events.Emit(&events.DownloadFailed{
+			Link:  d.URL,
+			Path:  d.Path,
+			Error: d.Error,
+			Card:  p.downloadCardFunc(d),
+		})
+		p.StatusWithTimeout(fmt.Sprintf("ERROR: downloading %s: %s", d.Title, d.Error), time.Second*3)
+	}
+	p.cb.Redraw()
+}
+
+// downloadCardFunc returns the card of the download for the lua events, nil if the card isn't loaded
+func (p *Photon) downloadCardFunc(d downloads.Download) func(*lua.LState) lua.LValue {
+	card := p.DownloadCard(d)
+	return func(L *lua.LState) lua.LValue {
+		if card == nil {
+			return lua.LNil
+		}
+		return newCard(card, L)
+	}
+}
+
+// sidecar is the metadata of the downloaded item, written next to the downloaded file
+type sidecar struct {
+	XMLName     xml.Name   `json:"-" xml:"episodedetails"`
+	Title       string     `json:"title" xml:"title"`
+	Feed        string     `json:"feed,omitempty" xml:"showtitle,omitempty"`
+	Author      string     `json:"author,omitempty" xml:"credits,omitempty"`
+	Description string     `json:"description,omitempty" xml:"plot,omitempty"`
+	Published   *time.Time `json:"published,omitempty" xml:"-"`
+	Aired       string     `json:"-" xml:"aired,omitempty"`
+	Link        string     `json:"link" xml:"uniqueid"`
+	URL         string     `json:"url" xml:"-"`
+}
+
+// writeSidecars writes the json and nfo metadata files of the finished download,
+// they are written once for the item, next to it's first file (the video, not the audio)
+func (p *Photon) writeSidecars(d downloads.Download) {
+	if len(p.downloadSidecars) == 0 || d.Path == "" || d.Part > 0 {
+		return
+	}
+	sc := sidecar{
+		Title:       d.Title,
+		Feed:        d.Feed,
+		Author:      d.Author,
+		Description: d.Description,
+		Link:        d.ItemLink,
+		URL:         d.URL,
+	}
+	if !d.Published.IsZero() {
+		sc.Published = &d.Published
+		sc.Aired = d.Published.Format("2006-01-02")
+	}
+	base := strings.TrimSuffix(d.Path, filepath.Ext(d.Path))
+	for _, kind := range p.downloadSidecars {
+		var (
+			data []byte
+			err  error
+		)
+		switch kind {
+		case "json":
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(sc)
+			data = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+		case "nfo":
+			data, err = xml.MarshalIndent(sc, "", "  ")
+			data = append([]byte(xml.Header), data...)
+		default:
+			log.Println("ERROR: unknown download sidecar:", kind)
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(base+"."+kind, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			log.Printf("ERROR: writing %s sidecar of %s: %s", kind, d.Path, err)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+// Package downloads is the download manager, it downloads the queued links with a number of workers,
+// interrupted downloads are resumed with HTTP range requests, the queue is kept in one json file
+package downloads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// progressInterval is how often the progress of a running download is reported
+const progressInterval = 500 * time.Millisecond
+
+type Status string
+
+const (
+	Queued   Status = "queued"
+	Running  Status = "running"
+	Paused   Status = "paused"
+	Done     Status = "done"
+	Failed   Status = "failed"
+	Canceled Status = "canceled"
+)
+
+// Download is a entry of the download queue
+type Download struct {
+	ID  int    `json:"id"`
+	URL string `json:"url"`
+	// Title is shown in the downloads view, ItemLink is the link of the item it's downloaded from
+	Title    string `json:"title"`
+	ItemLink string `json:"itemLink,omitempty"`
+	// Feed, Author, Description and Published are the item's metadata, for the sidecar files
+	Feed        string    `json:"feed,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Published   time.Time `json:"published,omitempty"`
+	// Part is the index of the link, when the item is downloaded from several links (video and audio)
+	Part int `json:"part,omitempty"`
+	// Dir and Name are where the file is saved, the {ext} placeholder in the Name is replaced
+	// with the extension of the content-type, without it the extension is appended,
+	// Path is the full path, it's set when the download starts and it's unique
+	Dir        string    `json:"dir"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path,omitempty"`
+	Status     Status    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	Downloaded int64     `json:"downloaded,omitempty"`
+	AddedAt    time.Time `json:"addedAt"`
+	// Speed is the download speed in bytes per second, it's set while the download runs
+	Speed float64 `json:"-"`
+}
+
+// Progress returns the downloaded part from 0 to 1, or -1 if the size isn't known
+func (d Download) Progress() float64 {
+	if d.Size <= 0 {
+		return -1
+	}
+	return float64(d.Downloaded) / float64(d.Size)
+}
+
+type EventType int
+
+const (
+	// Changed is sent when a download is added, removed or it's status changes
+	Changed EventType = iota
+	// Progress is sent periodically while the download runs
+	Progress
+	// Finished is sent when the download is done
+	Finished
+	// FailedEvent is sent when the download failed
+	FailedEvent
+)
+
+type Manager struct {
+	Client *http.Client
+	// Extension returns the file extension (without the dot) of the content-type, empty if it's unknown
+	Extension func(contentType string) string
+	// OnEvent is called with a copy of the download when it changes
+	OnEvent func(EventType, Download)
+
+	path      string
+	mu        sync.Mutex
+	downloads []*Download
+	nextID    int
+	// cancels stops the running downloads, by the download id
+	cancels map[int]context.CancelFunc
+	wake    chan struct{}
+}
+
+// New loads the download queue from the file at path, the interrupted downloads are queued again
+func New(path string) (*Manager, error) {
+	m := &Manager{
+		path:    path,
+		cancels: make(map[int]context.CancelFunc),
+		wake:    make(chan struct{}, 1),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m.downloads); err != nil {
+		return m, fmt.Errorf("parsing downloads: %w", err)
+	}
+	for _, d := range m.downloads {
+		if d.Status == Running {
+			d.Status = Queued
+		}
+		m.nextID = max(m.nextID, d.ID)
+	}
+	return m, nil
+}
+
+// Start runs the workers, they stop when the ctx is done
+func (m *Manager) Start(ctx context.Context, workers int) {
+	for range max(1, workers) {
+		go m.worker(ctx)
+	}
+	m.notify()
+}
+
+// Add queues the download, the URL, Dir and Name must be set
+func (m *Manager) Add(download Download) Download {
+	m.mu.Lock()
+	m.nextID++
+	d := &download
+	d.ID, d.Status, d.AddedAt = m.nextID, Queued, time.Now()
+	d.Path, d.Error, d.Size, d.Downloaded = "", "", 0, 0
+	m.downloads = append(m.downloads, d)
+	m.saveLocked()
+	m.mu.Unlock()
+	m.emit(Changed, *d)
+	m.notify()
+	return *d
+}
+
+// List returns the downloads, in the order they were added
+func (m *Manager) List() []Download {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Download, len(m.downloads))
+	for i, d := range m.downloads {
+		list[i] = *d
+	}
+	return list
+}
+
+// Pause stops the running or queued download, the downloaded part is kept,
+// a paused download is queued again
+func (m *Manager) Pause(id int) {
+	m.update(id, func(d *Download) {
+		switch d.Status {
+		case Queued, Running:
+			d.Status = Paused
+		case Paused:
+			d.Status = Queued
+		}
+	})
+}
+
+// Cancel stops the download and removes the downloaded part
+func (m *Manager) Cancel(id int) {
+	m.update(id, func(d *Download) {
+		if d.Status == Done || d.Status == Canceled {
+			return
+		}
+		d.Status = Canceled
+		if d.Path != "" {
+			os.Remove(d.Path + ".part")
+		}
+		d.Downloaded = 0
+	})
+}
+
+// Retry queues the failed or canceled download again
+func (m *Manager) Retry(id int) {
+	m.update(id, func(d *Download) {
+		if d.Status == Failed || d.Status == Canceled {
+			d.Status, d.Error = Queued, ""
+		}
+	})
+}
+
+// Remove removes the download from the list, a unfinished download is canceled
+func (m *Manager) Remove(id int) {
+	m.Cancel(id)
+	m.mu.Lock()
+	for i, d := range m.downloads {
+		if d.ID == id {
+			m.downloads = append(m.downloads[:i], m.downloads[i+1:]...)
+			m.saveLocked()
+			m.mu.Unlock()
+			m.emit(Changed, *d)
+			return
+		}
+	}
+	m.mu.Unlock()
+}
+
+// update changes the download with f, stops it if it isn't running anymore and wakes the workers
+func (m *Manager) update(id int, f func(*Download)) {
+	m.mu.Lock()
+	var d *Download
+	for _, dl := range m.downloads {
+		if dl.ID == id {
+			d = dl
+		}
+	}
+	if d == nil {
+		m.mu.Unlock()
+		return
+	}
+	f(d)
+	if cancel, ok := m.cancels[id]; ok && d.Status != Running {
+		cancel()
+		delete(m.cancels, id)
+	}
+	d.Speed = 0
+	m.saveLocked()
+	m.mu.Unlock()
+	m.emit(Changed, *d)
+	m.notify()
+}
+
+// notify wakes a idle worker
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) worker(ctx context.Context) {
+	for {
+		d, dctx := m.next(ctx)
+		if d == nil {
+			select {
+			case <-m.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		// there may be more queued downloads for the other workers
+		m.notify()
+		err := m.download(dctx, d)
+		m.finish(dctx, d, err)
+	}
+}
+
+// next marks the first queued download as running
+func (m *Manager) next(ctx context.Context) (*Download, context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.downloads {
+		if d.Status != Queued {
+			continue
+		}
+		d.Status = Running
+		dctx, cancel := context.WithCancel(ctx)
+		m.cancels[d.ID] = cancel
+		return d, dctx
+	}
+	return nil, nil
+}
+
+// finish sets the status of the download after it stopped
+func (m *Manager) finish(ctx context.Context, d *Download, err error) {
+	// paused, canceled or photon quits
+	stopped := ctx.Err() != nil
+	m.mu.Lock()
+	if cancel, ok := m.cancels[d.ID]; ok {
+		cancel()
+		delete(m.cancels, d.ID)
+	}
+	event := Changed
+	switch {
+	case stopped:
+	case err != nil:
+		d.Status, d.Error = Failed, err.Error()
+		event = FailedEvent
+		log.Printf("ERROR: downloading %s: %s", d.URL, err)
+	default:
+		d.Status = Done
+		event = Finished
+		log.Printf("INFO: downloaded link %s to %s", d.URL, d.Path)
+	}
+	d.Speed = 0
+	m.saveLocked()
+	c := *d
+	m.mu.Unlock()
+	m.emit(event, c)
+}
+
+// download downloads the link to the .part file, continuing where it stopped,
+// and renames it when it's complete
+func (m *Manager) download(ctx context.Context, d *Download) error {
+	m.mu.Lock()
+	link, filePath := d.URL, d.Path
+	m.mu.Unlock()
+	var offset int64
+	if filePath != "" {
+		if fi, err := os.Stat(filePath + ".part"); err == nil {
+			offset = fi.Size()
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	var size int64
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the part is the whole file
+		return m.complete(d, offset)
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		size = offset + resp.ContentLength
+	case resp.StatusCode == http.StatusOK:
+		// the server doesn't support ranges, the download starts again
+		offset = 0
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		size = resp.ContentLength
+	default:
+		return fmt.Errorf("http status: %s", resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		size = 0
+	}
+	var body io.Reader = resp.Body
+	if filePath == "" {
+		var ext string
+		ext, body, err = m.extension(resp, link)
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		filePath = m.reservePath(d, ext)
+		m.mu.Unlock()
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filePath+".part", flags, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m.mu.Lock()
+	d.Path, d.Size, d.Downloaded = filePath, size, offset
+	m.saveLocked()
+	m.mu.Unlock()
+
+	pw := &progressWriter{m: m, d: d, start: time.Now(), startBytes: offset}
+	if _, err := io.Copy(f, io.TeeReader(body, pw)); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return m.complete(d, -1)
+}
+
+// reservePath sets the path of the download to a unused path,
+// a number is added to the name if the file exists or a other download saves to it
+func (m *Manager) reservePath(d *Download, ext string) string {
+	name := d.Name
+	switch {
+	case strings.Contains(name, "{ext}"):
+		if ext == "" {
+			name = strings.ReplaceAll(name, ".{ext}", "")
+		}
+		name = strings.ReplaceAll(name, "{ext}", ext)
+	case ext != "" && !strings.EqualFold(path.Ext(name), "."+ext):
+		name += "." + ext
+	}
+	d.Path = UniquePath(filepath.Join(d.Dir, name), func(p string) bool {
+		return m.pathUsed(d, p)
+	})
+	return d.Path
+}
+
+// UniquePath returns the path, or the path with " (2)", " (3)", ... before the extension,
+// the first one that isn't used
+func UniquePath(path string, used func(string) bool) string {
+	ext := filepath.Ext(path)
+	p := path
+	for n := 2; used(p); n++ {
+		p = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(path, ext), n, ext)
+	}
+	return p
+}
+
+// pathUsed reports if the file exists, or a other unfinished download saves to the path
+func (m *Manager) pathUsed(d *Download, p string) bool {
+	if _, err := os.Stat(p); err == nil {
+		return true
+	}
+	if _, err := os.Stat(p + ".part"); err == nil {
+		return true
+	}
+	for _, other := range m.downloads {
+		if other != d && other.Path == p && other.Status != Done && other.Status != Canceled {
+			return true
+		}
+	}
+	return false
+}
+
+// complete renames the .part file to the download path
+func (m *Manager) complete(d *Download, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if size >= 0 {
+		d.Size, d.Downloaded = size, size
+	}
+	return os.Rename(d.Path+".part", d.Path)
+}
+
+// extension returns the extension by the content-type of the response, without a content-type
+// it's detected from the data, or it's taken from the link
+func (m *Manager) extension(resp *http.Response, link string) (string, io.Reader, error) {
+	var body io.Reader = resp.Body
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		var header strings.Builder
+		mtype, err := mimetype.DetectReader(io.TeeReader(resp.Body, &header))
+		if err != nil {
+			return "", nil, err
+		}
+		body = io.MultiReader(strings.NewReader(header.String()), resp.Body)
+		contentType = mtype.String()
+	}
+	contentType, _, _ = mime.ParseMediaType(contentType)
+	if m.Extension != nil && contentType != "application/octet-stream" {
+		if ext := m.Extension(contentType); ext != "" {
+			return ext, body, nil
+		}
+	}
+	if u, err := url.Parse(link); err == nil {
+		return strings.TrimPrefix(path.Ext(u.Path), "."), body, nil
+	}
+	return "", body, nil
+}
+
+func (m *Manager) emit(t EventType, d Download) {
+	if m.OnEvent != nil {
+		m.OnEvent(t, d)
+	}
+}
+
+func (m *Manager) saveLocked() {
+	if err := m.save(); err != nil {
+		log.Println("ERROR: saving downloads:", err)
+	}
+}
+
+func (m *Manager) save() error {
+	data, err := json.Marshal(m.downloads)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// progressWriter counts the downloaded bytes and reports the progress and speed
+type progressWriter struct {
+	m          *Manager
+	d          *Download
+	start      time.Time
+	startBytes int64
+	reported   time.Time
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.m.mu.Lock()
+	pw.d.Downloaded += int64(len(p))
+	if time.Since(pw.reported) < progressInterval {
+		pw.m.mu.Unlock()
+		return len(p), nil
+	}
+	pw.reported = time.Now()
+	if elapsed := time.Since(pw.start).Seconds(); elapsed > 0 {
+		pw.d.Speed = float64(pw.d.Downloaded-pw.startBytes) / elapsed
+	}
+	d := *pw.d
+	pw.m.mu.Unlock()
+	pw.m.emit(Progress, d)
+	return len(p), nil
+}
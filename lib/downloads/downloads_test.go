@@ -0,0 +1,73 @@
+package downloads
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReservePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"taken.mp4", "partial.mp4.part", "noext"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &Manager{}
+	// a other unfinished download saves to reserved.mp4, a finished one to done.mp4
+	m.downloads = []*Download{
+		{ID: 1, Path: filepath.Join(dir, "reserved.mp4"), Status: Running},
+		{ID: 2, Path: filepath.Join(dir, "done.mp4"), Status: Done},
+	}
+	tests := []struct {
+		name, ext string
+		want      string
+	}{
+		{name: "video", ext: "mp4", want: "video.mp4"},
+		// the extension isn't added twice, the case doesn't matter
+		{name: "video.MP4", ext: "mp4", want: "video.MP4"},
+		{name: "video", ext: "", want: "video"},
+		{name: "episode.{ext}", ext: "mp3", want: "episode.mp3"},
+		{name: "episode.{ext}", ext: "", want: "episode"},
+		{name: "taken", ext: "mp4", want: "taken (2).mp4"},
+		{name: "partial", ext: "mp4", want: "partial (2).mp4"},
+		{name: "reserved", ext: "mp4", want: "reserved (2).mp4"},
+		{name: "done", ext: "mp4", want: "done.mp4"},
+		{name: "noext", ext: "", want: "noext (2)"},
+	}
+	for _, tt := range tests {
+		d := &Download{Name: tt.name, Dir: dir}
+		want := filepath.Join(dir, tt.want)
+		if got := m.reservePath(d, tt.ext); got != want || d.Path != want {
+			t.Errorf("reservePath(%q, %q) = %q, want %q", tt.name, tt.ext, got, want)
+		}
+	}
+	// the download's own path isn't taken by itself
+	d := m.downloads[0]
+	d.Name = "reserved.mp4"
+	d.Dir = dir
+	if got, want := m.reservePath(d, "mp4"), filepath.Join(dir, "reserved.mp4"); got != want {
+		t.Errorf("reservePath of the reserving download = %q, want %q", got, want)
+	}
+}
+
+func TestUniquePath(t *testing.T) {
+	used := map[string]bool{
+		"/d/a.txt":     true,
+		"/d/a (2).txt": true,
+		"/d/b":         true,
+	}
+	isUsed := func(p string) bool { return used[p] }
+	tests := []struct {
+		path, want string
+	}{
+		{"/d/new.txt", "/d/new.txt"},
+		{"/d/a.txt", "/d/a (3).txt"},
+		{"/d/b", "/d/b (2)"},
+	}
+	for _, tt := range tests {
+		if got := UniquePath(tt.path, isUsed); got != tt.want {
+			t.Errorf("UniquePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -33,12 +33,17 @@ func Subscribe(et EventType, c EventCallback) {
 type EventType string
 
 const (
-	EventTypeInit            = EventType("Init")
-	EventTypeRunMediaStart   = EventType("RunMediaStart")
-	EventTypeRunMediaEnd     = EventType("RunMediaEnd")
-	EventTypeFeedsDownloaded = EventType("FeedsDownloaded")
-	EventTypeArticleOpened   = EventType("ArticleOpened")
-	EventTypeLinkOpened      = EventType("LinkOpened")
+	EventTypeInit             = EventType("Init")
+	EventTypeRunMediaStart    = EventType("RunMediaStart")
+	EventTypeRunMediaEnd      = EventType("RunMediaEnd")
+	EventTypeFeedsDownloaded  = EventType("FeedsDownloaded")
+	EventTypeArticleOpened    = EventType("ArticleOpened")
+	EventTypeLinkOpened       = EventType("LinkOpened")
+	EventTypeQueueChanged     = EventType("QueueChanged")
+	EventTypeQueueItemStarted = EventType("QueueItemStarted")
+	EventTypeQueueItemEnded   = EventType("QueueItemEnded")
+	EventTypeDownloadFinished = EventType("DownloadFinished")
+	EventTypeDownloadFailed   = EventType("DownloadFailed")
 )
 
 type Init struct{}
@@ -88,3 +93,48 @@ type LinkOpened struct {
 func (e *LinkOpened) Type() EventType {
 	return EventTypeLinkOpened
 }
+
+type QueueChanged struct{}
+
+func (e *QueueChanged) Type() EventType {
+	return EventTypeQueueChanged
+}
+
+type QueueItemStarted struct {
+	Link string
+	Card func(*lua.LState) lua.LValue
+}
+
+func (e *QueueItemStarted) Type() EventType {
+	return EventTypeQueueItemStarted
+}
+
+type QueueItemEnded struct {
+	Link string
+	Card func(*lua.LState) lua.LValue
+}
+
+func (e *QueueItemEnded) Type() EventType {
+	return EventTypeQueueItemEnded
+}
+
+type DownloadFinished struct {
+	Link string
+	Path string
+	Card func(*lua.LState) lua.LValue
+}
+
+func (e *DownloadFinished) Type() EventType {
+	return EventTypeDownloadFinished
+}
+
+type DownloadFailed struct {
+	Link  string
+	Path  string
+	Error string
+	Card  func(*lua.LState) lua.LValue
+}
+
+func (e *DownloadFailed) Type() EventType {
+	return EventTypeDownloadFailed
+}
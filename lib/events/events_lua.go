@@ -16,6 +16,11 @@ func New(L *lua.LState) lua.LValue {
 	L.SetField(mod, "FeedsDownloaded", lua.LString(EventTypeFeedsDownloaded))
 	L.SetField(mod, "ArticleOpened", lua.LString(EventTypeArticleOpened))
 	L.SetField(mod, "LinkOpened", lua.LString(EventTypeLinkOpened))
+	L.SetField(mod, "QueueChanged", lua.LString(EventTypeQueueChanged))
+	L.SetField(mod, "QueueItemStarted", lua.LString(EventTypeQueueItemStarted))
+	L.SetField(mod, "QueueItemEnded", lua.LString(EventTypeQueueItemEnded))
+	L.SetField(mod, "DownloadFinished", lua.LString(EventTypeDownloadFinished))
+	L.SetField(mod, "DownloadFailed", lua.LString(EventTypeDownloadFailed))
 	return mod
 }
 
@@ -70,6 +75,37 @@ func registerEvents(L *lua.LState) {
 	mt = L.NewTypeMetatable(string(EventTypeLinkOpened))
 	L.SetGlobal(string(EventTypeLinkOpened), mt)
 	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), methods))
+
+	// QueueChanged
+	mt = L.NewTypeMetatable(string(EventTypeQueueChanged))
+	L.SetGlobal(string(EventTypeQueueChanged), mt)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), nil))
+
+	// QueueItemStarted
+	mt = L.NewTypeMetatable(string(EventTypeQueueItemStarted))
+	L.SetGlobal(string(EventTypeQueueItemStarted), mt)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), methods))
+
+	// QueueItemEnded
+	mt = L.NewTypeMetatable(string(EventTypeQueueItemEnded))
+	L.SetGlobal(string(EventTypeQueueItemEnded), mt)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), methods))
+
+	// DownloadFinished
+	downloadMethods := map[string]lua.LGFunction{
+		"link":  eventLink,
+		"card":  eventCard,
+		"path":  eventPath,
+		"error": eventError,
+	}
+	mt = L.NewTypeMetatable(string(EventTypeDownloadFinished))
+	L.SetGlobal(string(EventTypeDownloadFinished), mt)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), downloadMethods))
+
+	// DownloadFailed
+	mt = L.NewTypeMetatable(string(EventTypeDownloadFailed))
+	L.SetGlobal(string(EventTypeDownloadFailed), mt)
+	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), downloadMethods))
 }
 
 func eventLink(L *lua.LState) int {
@@ -86,6 +122,14 @@ func eventLink(L *lua.LState) int {
 		L.Push(lua.LString(e.Link))
 	case *LinkOpened:
 		L.Push(lua.LString(e.Link))
+	case *QueueItemStarted:
+		L.Push(lua.LString(e.Link))
+	case *QueueItemEnded:
+		L.Push(lua.LString(e.Link))
+	case *DownloadFinished:
+		L.Push(lua.LString(e.Link))
+	case *DownloadFailed:
+		L.Push(lua.LString(e.Link))
 	case *FeedsDownloaded:
 		L.ArgError(1, "feedsDownloaded event doesn't have link method")
 		return 0
@@ -110,6 +154,14 @@ func eventCard(L *lua.LState) int {
 		L.Push(e.Card(L))
 	case *LinkOpened:
 		L.Push(e.Card(L))
+	case *QueueItemStarted:
+		L.Push(e.Card(L))
+	case *QueueItemEnded:
+		L.Push(e.Card(L))
+	case *DownloadFinished:
+		L.Push(e.Card(L))
+	case *DownloadFailed:
+		L.Push(e.Card(L))
 	case *FeedsDownloaded:
 		L.ArgError(1, "feedsDownloaded event doesn't have link method")
 		return 0
@@ -120,6 +172,34 @@ func eventCard(L *lua.LState) int {
 	return 1
 }
 
+func eventPath(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+	switch e := ud.Value.(type) {
+	case *DownloadFinished:
+		L.Push(lua.LString(e.Path))
+	case *DownloadFailed:
+		L.Push(lua.LString(e.Path))
+	default:
+		L.ArgError(1, "download event expected")
+		return 0
+	}
+	return 1
+}
+
+func eventError(L *lua.LState) int {
+	ud := L.CheckUserData(1)
+	switch e := ud.Value.(type) {
+	case *DownloadFinished:
+		L.Push(lua.LNil)
+	case *DownloadFailed:
+		L.Push(lua.LString(e.Error))
+	default:
+		L.ArgError(1, "download event expected")
+		return 0
+	}
+	return 1
+}
+
 func eventToLuaValue(L *lua.LState, e Event) lua.LValue {
 	ud := L.NewUserData()
 	ud.Value = e
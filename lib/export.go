@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/downloads"
+	"git.sr.ht/~ghost08/photon/lib/export"
+	"github.com/kennygrant/sanitize"
+)
+
+type ExportFormat string
+
+const (
+	ExportMarkdown ExportFormat = "md"
+	ExportHTML     ExportFormat = "html"
+	ExportEPUB     ExportFormat = "epub"
+)
+
+// exportFetchTimeout is the timeout of loading one article and it's images
+const exportFetchTimeout = time.Minute
+
+// Export writes the card's article to the download path in the format,
+// the article is loaded in the background if it isn't already
+func (card *Card) Export(format ExportFormat) {
+	if card == nil {
+		return
+	}
+	card.photon.SetStatusWithSpinner(fmt.Sprintf("Exporting %s", card.Item.Title))
+	go func() {
+		path, err := card.export(format)
+		if err != nil {
+			log.Printf("ERROR: exporting article (%s): %s", card.Item.Link, err)
+			card.photon.StatusWithTimeout(fmt.Sprintf("ERROR: exporting article: %s", err), time.Second*3)
+			return
+		}
+		log.Printf("INFO: exported %s to %s", card.Item.Link, path)
+		card.photon.StatusWithTimeout(fmt.Sprintf("Exported to %s", path), time.Second*5)
+	}()
+}
+
+func (card *Card) export(format ExportFormat) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), exportFetchTimeout)
+	defer cancel()
+	doc, err := card.exportDocument(ctx)
+	if err != nil {
+		return "", err
+	}
+	fetch := card.photon.exportFetcher(ctx)
+	return card.photon.writeExport(card.Item.Title, format, func(w io.Writer) error {
+		switch format {
+		case ExportMarkdown:
+			return export.Markdown(w, doc)
+		case ExportHTML:
+			return export.HTML(w, doc, fetch)
+		case ExportEPUB:
+			return export.EPUB(w, doc.Title, []export.Document{doc}, fetch)
+		}
+		return fmt.Errorf("unknown export format: %s", format)
+	})
+}
+
+// ExportEPUB writes the articles of the cards to one EPUB book in the download path
+func (p *Photon) ExportEPUB(cards Cards) {
+	if len(cards) == 0 {
+		return
+	}
+	title := fmt.Sprintf("photon %s", time.Now().Format("2006-01-02 15:04"))
+	p.SetStatusWithSpinner(fmt.Sprintf("Exporting %d articles", len(cards)))
+	go func() {
+		var docs []export.Document
+		for i, card := range cards {
+			p.SetStatusWithSpinner(fmt.Sprintf("Exporting %d/%d", i+1, len(cards)))
+			ctx, cancel := context.WithTimeout(context.Background(), exportFetchTimeout)
+			doc, err := card.exportDocument(ctx)
+			cancel()
+			if err != nil {
+				log.Printf("ERROR: exporting article (%s): %s", card.Item.Link, err)
+				continue
+			}
+			docs = append(docs, doc)
+		}
+		if len(docs) == 0 {
+			p.StatusWithTimeout("ERROR: exporting articles: no article loaded", time.Second*3)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportFetchTimeout*time.Duration(len(docs)))
+		defer cancel()
+		path, err := p.writeExport(title, ExportEPUB, func(w io.Writer) error {
+			return export.EPUB(w, title, docs, p.exportFetcher(ctx))
+		})
+		if err != nil {
+			log.Println("ERROR: exporting articles:", err)
+			p.StatusWithTimeout(fmt.Sprintf("ERROR: exporting articles: %s", err), time.Second*3)
+			return
+		}
+		log.Printf("INFO: exported %d articles to %s", len(docs), path)
+		p.StatusWithTimeout(fmt.Sprintf("Exported %d articles to %s", len(docs), path), time.Second*5)
+	}()
+}
+
+// exportDocument loads the card's article and returns it for exporting
+func (card *Card) exportDocument(ctx context.Context) (export.Document, error) {
+	article, err := card.loadArticle(ctx)
+	if err != nil {
+		return export.Document{}, fmt.Errorf("loading article: %w", err)
+	}
+	doc := export.Document{
+		Title:    article.Title,
+		Byline:   article.Byline,
+		SiteName: article.SiteName,
+		Link:     card.Item.Link,
+		Language: article.Language,
+		Content:  article.Content,
+	}
+	if doc.Title == "" {
+		doc.Title = card.Item.Title
+	}
+	if doc.SiteName == "" && card.Feed != nil {
+		doc.SiteName = card.Feed.Title
+	}
+	switch {
+	case article.PublishedTime != nil:
+		doc.Published = *article.PublishedTime
+	case card.Item.PublishedParsed != nil:
+		doc.Published = *card.Item.PublishedParsed
+	}
+	if doc.Content == "" {
+		// plain text articles don't have html content
+		doc.Content = "<pre>" + html.EscapeString(article.TextContent) + "</pre>"
+	}
+	return doc, nil
+}
+
+func (p *Photon) exportFetcher(ctx context.Context) export.Fetcher {
+	return func(link string) ([]byte, error) {
+		return p.fetchBytes(ctx, link)
+	}
+}
+
+// writeExport creates the file for the export in the download path and writes it with write
+func (p *Photon) writeExport(name string, format ExportFormat, write func(io.Writer) error) (string, error) {
+	dir, err := p.downloadDir()
+	if err != nil {
+		return "", err
+	}
+	// a previous export with the same title isn't overwritten
+	path := downloads.UniquePath(filepath.Join(dir, sanitize.Name(name)+"."+string(format)), func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	})
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	return path, f.Close()
+}
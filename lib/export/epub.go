@@ -0,0 +1,284 @@
+package export
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// EPUB writes the documents as a EPUB 3 book, one chapter per document,
+// the images are downloaded with fetch and stored in the book
+func EPUB(w io.Writer, title string, docs []Document, fetch Fetcher) error {
+	zw := zip.NewWriter(w)
+	// the mimetype must be the first file and it can't be compressed
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "application/epub+zip"); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "META-INF/container.xml", containerXML); err != nil {
+		return err
+	}
+
+	b := &book{zw: zw, fetch: fetch, images: make(map[string]string)}
+	for i, doc := range docs {
+		name := fmt.Sprintf("chapter%03d.xhtml", i+1)
+		if err := b.addChapter(name, doc); err != nil {
+			return fmt.Errorf("writing chapter %q: %w", doc.Title, err)
+		}
+	}
+	if err := writeZipFile(zw, "OEBPS/nav.xhtml", b.nav(title)); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "OEBPS/toc.ncx", b.ncx(title)); err != nil {
+		return err
+	}
+	if err := writeZipFile(zw, "OEBPS/content.opf", b.opf(title, docs)); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+type chapter struct {
+	file, title string
+}
+
+type manifestItem struct {
+	id, href, mediaType string
+}
+
+type book struct {
+	zw       *zip.Writer
+	fetch    Fetcher
+	chapters []chapter
+	manifest []manifestItem
+	// images maps the image link to it's file in the book, empty if the download failed
+	images map[string]string
+}
+
+func (b *book) addChapter(name string, doc Document) error {
+	nodes, err := parseContent(doc.Content)
+	if err != nil {
+		return err
+	}
+	base, _ := url.Parse(doc.Link)
+	forEachImage(nodes, base, func(img *nethtml.Node, src string) {
+		file, ok := b.images[src]
+		if !ok {
+			var err error
+			if file, err = b.addImage(src); err != nil {
+				log.Printf("ERROR: export - downloading image (%s): %s", src, err)
+			}
+			b.images[src] = file
+		}
+		if file == "" {
+			// images outside the book aren't allowed, the alt text is kept
+			alt := getAttr(img, "alt")
+			img.Data, img.DataAtom, img.Attr = "span", atom.Span, nil
+			if alt != "" {
+				img.AppendChild(&nethtml.Node{Type: nethtml.TextNode, Data: alt})
+			}
+			return
+		}
+		setAttr(img, "src", file)
+		// the alt attribute is required in xhtml
+		setAttr(img, "alt", getAttr(img, "alt"))
+	})
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<!DOCTYPE html>` + "\n")
+	sb.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">` + "\n")
+	fmt.Fprintf(&sb, "<head>\n<meta charset=\"utf-8\"/>\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(doc.Title))
+	sb.WriteString(doc.header())
+	for _, n := range nodes {
+		writeXHTML(&sb, n)
+	}
+	sb.WriteString("\n</body>\n</html>\n")
+	if err := writeZipFile(b.zw, "OEBPS/"+name, sb.String()); err != nil {
+		return err
+	}
+	id := strings.TrimSuffix(name, ".xhtml")
+	b.chapters = append(b.chapters, chapter{file: name, title: doc.Title})
+	b.manifest = append(b.manifest, manifestItem{id: id, href: name, mediaType: "application/xhtml+xml"})
+	return nil
+}
+
+// addImage downloads the image and stores it in the book, it returns the file name of the image
+func (b *book) addImage(src string) (string, error) {
+	data, err := b.fetch(src)
+	if err != nil {
+		return "", err
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		// DetectContentType doesn't know svg
+		if strings.HasSuffix(strings.ToLower(src), ".svg") {
+			mediaType = "image/svg+xml"
+		} else {
+			return "", fmt.Errorf("not a image: %s", mediaType)
+		}
+	}
+	ext := ".img"
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		ext = exts[len(exts)-1]
+	}
+	sum := sha1.Sum([]byte(src))
+	id := "img" + hex.EncodeToString(sum[:8])
+	file := "images/" + id + ext
+	zf, err := b.zw.Create("OEBPS/" + file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zf.Write(data); err != nil {
+		return "", err
+	}
+	b.manifest = append(b.manifest, manifestItem{id: id, href: file, mediaType: mediaType})
+	return file, nil
+}
+
+func (b *book) nav(title string) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<!DOCTYPE html>` + "\n")
+	sb.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">` + "\n")
+	fmt.Fprintf(&sb, "<head>\n<meta charset=\"utf-8\"/>\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(title))
+	sb.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n<h1>Contents</h1>\n<ol>\n")
+	for _, ch := range b.chapters {
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a></li>\n", ch.file, html.EscapeString(ch.title))
+	}
+	sb.WriteString("</ol>\n</nav>\n</body>\n</html>\n")
+	return sb.String()
+}
+
+// ncx is the table of contents for EPUB 2 readers
+func (b *book) ncx(title string) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">` + "\n")
+	fmt.Fprintf(&sb, "<head><meta name=\"dtb:uid\" content=\"%s\"/></head>\n", b.uid(title))
+	fmt.Fprintf(&sb, "<docTitle><text>%s</text></docTitle>\n<navMap>\n", html.EscapeString(title))
+	for i, ch := range b.chapters {
+		fmt.Fprintf(&sb, "<navPoint id=\"nav%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+			i+1, i+1, html.EscapeString(ch.title), ch.file)
+	}
+	sb.WriteString("</navMap>\n</ncx>\n")
+	return sb.String()
+}
+
+func (b *book) opf(title string, docs []Document) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	sb.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="uid">` + "\n")
+	sb.WriteString(`<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&sb, "<dc:identifier id=\"uid\">%s</dc:identifier>\n", b.uid(title))
+	fmt.Fprintf(&sb, "<dc:title>%s</dc:title>\n", html.EscapeString(title))
+	lang := ""
+	authors := make(map[string]bool)
+	for _, doc := range docs {
+		if lang == "" {
+			lang = doc.Language
+		}
+		if doc.Byline != "" && !authors[doc.Byline] {
+			authors[doc.Byline] = true
+			fmt.Fprintf(&sb, "<dc:creator>%s</dc:creator>\n", html.EscapeString(doc.Byline))
+		}
+	}
+	if lang == "" {
+		lang = "en"
+	}
+	fmt.Fprintf(&sb, "<dc:language>%s</dc:language>\n", html.EscapeString(lang))
+	fmt.Fprintf(&sb, "<meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	sb.WriteString("</metadata>\n<manifest>\n")
+	sb.WriteString(`<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	sb.WriteString(`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+	for _, item := range b.manifest {
+		fmt.Fprintf(&sb, "<item id=\"%s\" href=\"%s\" media-type=\"%s\"/>\n", item.id, item.href, item.mediaType)
+	}
+	sb.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+	for _, ch := range b.chapters {
+		fmt.Fprintf(&sb, "<itemref idref=\"%s\"/>\n", strings.TrimSuffix(ch.file, ".xhtml"))
+	}
+	sb.WriteString("</spine>\n</package>\n")
+	return sb.String()
+}
+
+// uid is the identifier of the book, made from the title and the chapters
+func (b *book) uid(title string) string {
+	h := sha1.New()
+	io.WriteString(h, title)
+	for _, ch := range b.chapters {
+		io.WriteString(h, ch.title)
+	}
+	return "urn:photon:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func writeZipFile(zw *zip.Writer, name, content string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
+
+// VoidElements are the html elements without the end tag
+var VoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// writeXHTML writes the node as xhtml, html.Render doesn't close the void elements
+// and doesn't escape the text the way xml parsers need
+func writeXHTML(sb *strings.Builder, n *nethtml.Node) {
+	switch n.Type {
+	case nethtml.TextNode:
+		sb.WriteString(html.EscapeString(n.Data))
+	case nethtml.ElementNode:
+		sb.WriteString("<" + n.Data)
+		seen := make(map[string]bool, len(n.Attr))
+		for _, a := range n.Attr {
+			// namespaced and duplicate attributes aren't valid xml
+			if a.Namespace != "" || strings.ContainsAny(a.Key, ":\"'<>/=") || seen[a.Key] {
+				continue
+			}
+			seen[a.Key] = true
+			fmt.Fprintf(sb, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+		}
+		if VoidElements[n.Data] {
+			sb.WriteString("/>")
+			return
+		}
+		sb.WriteString(">")
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeXHTML(sb, child)
+		}
+		sb.WriteString("</" + n.Data + ">")
+	case nethtml.DocumentNode:
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			writeXHTML(sb, child)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package export writes articles to Markdown, standalone HTML and EPUB
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Document is a article to export
+type Document struct {
+	Title     string
+	Byline    string
+	SiteName  string
+	Link      string
+	Language  string
+	Published time.Time
+	// Content is the html content of the article
+	Content string
+}
+
+// Fetcher downloads the images of the documents
+type Fetcher func(link string) ([]byte, error)
+
+// Markdown writes the document as markdown, with a header of the title, byline and link
+func Markdown(w io.Writer, doc Document) error {
+	converter := md.NewConverter(domain(doc.Link), true, nil)
+	content, err := converter.ConvertString(doc.Content)
+	if err != nil {
+		return fmt.Errorf("converting to markdown: %w", err)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", doc.Title)
+	if meta := doc.meta(); meta != "" {
+		fmt.Fprintf(&sb, "*%s*\n\n", meta)
+	}
+	if doc.Link != "" {
+		fmt.Fprintf(&sb, "<%s>\n\n", doc.Link)
+	}
+	sb.WriteString(strings.TrimSpace(content))
+	sb.WriteString("\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// HTML writes the document as a standalone html page, the images are inlined as data URIs
+func HTML(w io.Writer, doc Document, fetch Fetcher) error {
+	nodes, err := parseContent(doc.Content)
+	if err != nil {
+		return err
+	}
+	base, _ := url.Parse(doc.Link)
+	forEachImage(nodes, base, func(img *nethtml.Node, src string) {
+		data, err := fetch(src)
+		if err != nil {
+			log.Printf("ERROR: export - downloading image (%s): %s", src, err)
+			// relative links don't work in the exported file
+			setAttr(img, "src", src)
+			return
+		}
+		setAttr(img, "src", "data:"+http.DetectContentType(data)+";base64,"+base64.StdEncoding.EncodeToString(data))
+	})
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n")
+	fmt.Fprintf(&sb, "<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n", html.EscapeString(doc.Language))
+	fmt.Fprintf(&sb, "<title>%s</title>\n", html.EscapeString(doc.Title))
+	sb.WriteString("<style>body{max-width:40em;margin:2em auto;padding:0 1em;font-family:serif;line-height:1.5}img{max-width:100%;height:auto}pre{overflow-x:auto}</style>\n")
+	sb.WriteString("</head>\n<body>\n")
+	sb.WriteString(doc.header())
+	for _, n := range nodes {
+		if err := nethtml.Render(&sb, n); err != nil {
+			return err
+		}
+	}
+	sb.WriteString("\n</body>\n</html>\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// meta returns the byline, site name and publish date of the document
+func (doc Document) meta() string {
+	var meta []string
+	for _, s := range []string{doc.Byline, doc.SiteName} {
+		if s = strings.TrimSpace(s); s != "" {
+			meta = append(meta, s)
+		}
+	}
+	if !doc.Published.IsZero() {
+		meta = append(meta, doc.Published.Format("2006-01-02"))
+	}
+	return strings.Join(meta, " · ")
+}
+
+// header returns the html header of the document with the title, meta and link
+func (doc Document) header() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<h1>%s</h1>\n", html.EscapeString(doc.Title))
+	if meta := doc.meta(); meta != "" {
+		fmt.Fprintf(&sb, "<p><em>%s</em></p>\n", html.EscapeString(meta))
+	}
+	if doc.Link != "" {
+		fmt.Fprintf(&sb, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(doc.Link), html.EscapeString(doc.Link))
+	}
+	return sb.String()
+}
+
+func parseContent(content string) ([]*nethtml.Node, error) {
+	nodes, err := nethtml.ParseFragment(
+		strings.NewReader(content),
+		&nethtml.Node{Type: nethtml.ElementNode, Data: "body", DataAtom: atom.Body},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("parsing article content: %w", err)
+	}
+	return nodes, nil
+}
+
+// forEachImage calls f for every <img> with it's absolute src,
+// srcset is removed, so the exported image is used
+func forEachImage(nodes []*nethtml.Node, base *url.URL, f func(img *nethtml.Node, src string)) {
+	var walk func(*nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		if n.Type == nethtml.ElementNode && n.Data == "img" {
+			removeAttr(n, "srcset")
+			removeAttr(n, "sizes")
+			if src := getAttr(n, "src"); src != "" && !strings.HasPrefix(src, "data:") {
+				if base != nil {
+					if u, err := base.Parse(src); err == nil {
+						src = u.String()
+					}
+				}
+				f(n, src)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+}
+
+func getAttr(n *nethtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *nethtml.Node, key, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, nethtml.Attribute{Key: key, Val: val})
+}
+
+func removeAttr(n *nethtml.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+// domain returns the scheme and host of the link, for resolving relative links
+func domain(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/history"
+	"github.com/mmcdole/gofeed"
+)
+
+// History returns the opened articles and played media, from the last opened
+func (p *Photon) History() []history.Record {
+	return p.history.Recent()
+}
+
+// HistoryRecord returns the history of the card's item
+func (card *Card) HistoryRecord() (history.Record, bool) {
+	return card.photon.history.Get(card.Item.Link)
+}
+
+// IsRead reports if the card's article was read to the end
+func (card *Card) IsRead() bool {
+	r, ok := card.HistoryRecord()
+	return ok && r.Read
+}
+
+// addHistory records that the item was opened as article or played
+func (card *Card) addHistory(kind history.Kind) {
+	card.photon.history.Update(card.Item.Link, func(r *history.Record) {
+		r.Title = card.Item.Title
+		r.Feed = card.Feed.Title
+		if card.Item.Image != nil {
+			r.Image = card.Item.Image.URL
+		}
+		r.Kind = kind
+		r.OpenedAt = time.Now()
+	})
+}
+
+// SaveReadingPosition stores the article view mode and scroll position,
+// once the article is read to the end it stays read
+func (card *Card) SaveReadingPosition(mode string, scrollOffset int, read bool) {
+	card.photon.history.Update(card.Item.Link, func(r *history.Record) {
+		r.Mode = mode
+		r.ScrollOffset = scrollOffset
+		r.Read = r.Read || read
+	})
+}
+
+// HistoryCard returns the loaded card of the history record,
+// if the item isn't in the feeds anymore, a card is created from the record
+func (p *Photon) HistoryCard(r history.Record) *Card {
+	for _, card := range p.Cards {
+		if card.Item.Link == r.Link {
+			return card
+		}
+	}
+	published := r.OpenedAt
+	item := &gofeed.Item{
+		Title:           r.Title,
+		Link:            r.Link,
+		PublishedParsed: &published,
+		Custom:          map[string]string{},
+	}
+	if r.Image != "" {
+		item.Image = &gofeed.Image{URL: r.Image}
+	}
+	card := &Card{
+		photon:     p,
+		Item:       item,
+		Feed:       &gofeed.Feed{Title: r.Feed},
+		Foreground: -1,
+		Background: -1,
+	}
+	card.offlineReady.Store(p.offlineStore.Has(r.Link))
+	return card
+}
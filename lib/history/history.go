@@ -0,0 +1,175 @@
+// Package history stores the reading position, read status and open time of the items,
+// the records are kept in one json file
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRecords is the number of records kept, the oldest are removed
+const maxRecords = 2000
+
+// saveDelay is how long the changes are batched, before the history file is written
+const saveDelay = time.Second * 2
+
+type Kind string
+
+const (
+	KindArticle Kind = "article"
+	KindMedia   Kind = "media"
+)
+
+// Record is the history of one item, identified by it's link
+type Record struct {
+	Link  string `json:"link"`
+	Title string `json:"title"`
+	Feed  string `json:"feed"`
+	Image string `json:"image,omitempty"`
+	// Kind is how the item was opened the last time
+	Kind     Kind      `json:"kind"`
+	OpenedAt time.Time `json:"openedAt"`
+	// Mode and ScrollOffset are the article view mode and position
+	Mode         string `json:"mode,omitempty"`
+	ScrollOffset int    `json:"scrollOffset,omitempty"`
+	// Read is set when the article was scrolled to the end, or the media played to the end
+	Read bool `json:"read,omitempty"`
+	// MediaPosition and MediaDuration are the playback position of the media, for resuming it
+	MediaPosition time.Duration `json:"mediaPosition,omitempty"`
+	MediaDuration time.Duration `json:"mediaDuration,omitempty"`
+}
+
+type Store struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]*Record
+	// saveTimer writes the batched changes, it's set while the changes aren't saved
+	saveTimer *time.Timer
+	// readOnly is set, when the history file couldn't be parsed and moved away,
+	// so it isn't overwritten
+	readOnly bool
+}
+
+// New loads the history from the file at path, a file that can't be parsed is moved to path.corrupt,
+// so the new history doesn't overwrite it
+func New(path string) (*Store, error) {
+	s := &Store{
+		path:    path,
+		records: make(map[string]*Record),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	var records []*Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		corrupt := path + ".corrupt"
+		if rerr := os.Rename(path, corrupt); rerr != nil {
+			s.readOnly = true
+			return s, fmt.Errorf("parsing history (it won't be saved): %w", err)
+		}
+		return s, fmt.Errorf("parsing history (moved to %s): %w", corrupt, err)
+	}
+	for _, r := range records {
+		s.records[r.Link] = r
+	}
+	return s, nil
+}
+
+// Get returns the record of the link
+func (s *Store) Get(link string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[link]
+	if !ok {
+		return Record{}, false
+	}
+	return *r, true
+}
+
+// Update changes the record of the link with f, if the link doesn't have a record a new one is created,
+// the history is saved after the saveDelay, with the changes made until then
+func (s *Store) Update(link string, f func(*Record)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[link]
+	if !ok {
+		r = &Record{Link: link}
+		s.records[link] = r
+	}
+	f(r)
+	if s.saveTimer == nil {
+		s.saveTimer = time.AfterFunc(saveDelay, func() {
+			if err := s.Flush(); err != nil {
+				log.Println("ERROR: saving history:", err)
+			}
+		})
+	}
+}
+
+// Flush saves the changes, that weren't saved yet
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveTimer == nil {
+		return nil
+	}
+	s.saveTimer.Stop()
+	s.saveTimer = nil
+	return s.save()
+}
+
+// Recent returns the records sorted from the last opened
+func (s *Store) Recent() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].OpenedAt.After(records[j].OpenedAt)
+	})
+	return records
+}
+
+func (s *Store) save() error {
+	if s.readOnly {
+		return errors.New("the history file couldn't be parsed, it isn't overwritten")
+	}
+	records := make([]*Record, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].OpenedAt.After(records[j].OpenedAt)
+	})
+	if len(records) > maxRecords {
+		for _, r := range records[maxRecords:] {
+			delete(s.records, r.Link)
+		}
+		records = records[:maxRecords]
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
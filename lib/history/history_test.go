@@ -0,0 +1,49 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Update("https://a.org/1", func(r *Record) { r.Title = "one" })
+	s.Update("https://a.org/1", func(r *Record) { r.Read = true })
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := loaded.Get("https://a.org/1"); !ok || r.Title != "one" || !r.Read {
+		t.Errorf("Get() = %+v, %v, want the saved record", r, ok)
+	}
+}
+
+func TestCorruptStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("[{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(path)
+	if err == nil {
+		t.Fatal("New() of a corrupt history: want error")
+	}
+	// the corrupt file is kept, the new history is saved in it's place
+	if data, err := os.ReadFile(path + ".corrupt"); err != nil || string(data) != "[{" {
+		t.Errorf("corrupt history file = %q, %v", data, err)
+	}
+	s.Update("https://a.org/1", func(r *Record) {})
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(path); err != nil {
+		t.Errorf("New() of the new history: %s", err)
+	}
+}
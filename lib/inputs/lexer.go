@@ -18,6 +18,7 @@ const (
 	itemURL
 	itemCmd
 	itemComment
+	itemOption
 )
 
 type item struct {
@@ -163,6 +164,8 @@ func lexStart(l *lexer) stateFn {
 		return lexCommand
 	case "http", "https":
 		return lexURL
+	case "downloads":
+		return lexOption
 	default:
 		r := l.peek()
 		switch r {
@@ -210,6 +213,16 @@ func lexURL(l *lexer) stateFn {
 	return lexStart
 }
 
+// lexOption lexes a option of the previous feed (downloads: ~/Videos/show)
+func lexOption(l *lexer) stateFn {
+	if r := l.read(); r != ':' {
+		return l.errorf("unexpected character after %s (%r) expected colon (:)", l.buf.String(), r)
+	}
+	l.acceptToLineBreak()
+	l.emit(itemOption)
+	return lexStart
+}
+
 func lexComment(l *lexer) stateFn {
 	l.buf.Reset()
 	l.acceptToLineBreak()
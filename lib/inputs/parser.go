@@ -4,16 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
+// FeedOptions are the options of a feed, they are set on the lines after the feed
+type FeedOptions struct {
+	// DownloadDir is where the media of the feed is downloaded (downloads: ~/Videos/show)
+	DownloadDir string
+}
+
+// Options are the feed options by the feed url/command
+type Options map[string]FeedOptions
+
 // Parse parses the photon config input, and retunts the list of urls/commands
 func Parse(r io.Reader) (Inputs, error) {
+	urls, _, err := ParseWithOptions(r)
+	return urls, err
+}
+
+// ParseWithOptions parses the photon config input, and returns the list of urls/commands with their options
+func ParseWithOptions(r io.Reader) (Inputs, Options, error) {
 	s := &scanner{l: lex(r)}
-	urls, err := parseConf(s)
+	urls, options, err := parseConf(s)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+		return nil, nil, fmt.Errorf("error parsing config file: %w", err)
 	}
-	return urls, nil
+	return urls, options, nil
 }
 
 type scanner struct {
@@ -32,18 +48,31 @@ func (s *scanner) next() item {
 	return i
 }
 
-func parseConf(s *scanner) (Inputs, error) {
+func parseConf(s *scanner) (Inputs, Options, error) {
 	var urls Inputs
+	options := make(Options)
 	for i := s.next(); i.typ != itemEOF; i = s.next() {
 		switch i.typ {
 		case itemError:
-			return nil, errors.New(i.val)
+			return nil, nil, errors.New(i.val)
 		case itemComment:
 		case itemCmd, itemURL:
 			urls = append(urls, i.val)
+		case itemOption:
+			if len(urls) == 0 {
+				return nil, nil, fmt.Errorf("option (%s) before the first feed in config file", i)
+			}
+			feed := urls[len(urls)-1]
+			name, value, _ := strings.Cut(i.val, ":")
+			o := options[feed]
+			switch name {
+			case "downloads":
+				o.DownloadDir = strings.TrimSpace(value)
+			}
+			options[feed] = o
 		default:
-			return nil, fmt.Errorf("unexpected item (%s) in config file", i)
+			return nil, nil, fmt.Errorf("unexpected item (%s) in config file", i)
 		}
 	}
-	return urls, nil
+	return urls, options, nil
 }
@@ -8,15 +8,19 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
-	"sort"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+	"git.sr.ht/~ghost08/photon/lib/downloads"
 	"git.sr.ht/~ghost08/photon/lib/events"
+	"git.sr.ht/~ghost08/photon/lib/history"
 	"git.sr.ht/~ghost08/photon/lib/inputs"
 	"git.sr.ht/~ghost08/photon/lib/keybindings"
 	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/offline"
+	"git.sr.ht/~ghost08/photon/lib/siteconfig"
 	"git.sr.ht/~ghost08/photon/lib/states"
 	"github.com/mmcdole/gofeed"
 	lua "github.com/yuin/gopher-lua"
@@ -24,13 +28,40 @@ import (
 
 type Photon struct {
 	feedInputs     *inputs.Inputs
+	feedOptions    inputs.Options
 	ImgDownloader  *ImgDownloader
 	mediaExtractor *media.Extractor
 	httpClient     *http.Client
 	KeyBindings    *keybindings.Registry
 	downloadPath   string
-	cb             Callbacks
-	luaState       *lua.LState
+	downloads      *downloads.Manager
+	// downloadName is the file name template of the downloads
+	downloadName string
+	// downloadSidecars are the metadata files written next to the downloads (json, nfo)
+	downloadSidecars []string
+	// number of links downloaded at the same time
+	downloadWorkers int
+	cb              Callbacks
+	luaState        *lua.LState
+	offlineStore    *offline.Store
+	offlineSync     offlineSync
+	autoDownloader  *autoDownloader
+	siteConfigs     *siteconfig.Registry
+	// maximum number of pages of a multi-page article
+	articleMaxPages int
+	articleModes    []ArticleMode
+	// linkHandlers open the links matching them, the first matching one is used
+	linkHandlers []LinkHandler
+	history      *history.Store
+	queue        *media.Queue
+	// torrentLink is the preferred link of the torrents, magnet or torrent
+	torrentLink string
+	// sortBy is the order of the cards, see SortOrders
+	sortBy string
+	// processes are the players and commands started by photon
+	processes *media.Registry
+	// onExit is the policy of the running processes on exit, see WithOnExit
+	onExit string
 
 	Cards         Cards
 	VisibleCards  Cards
@@ -46,6 +77,9 @@ type Callbacks interface {
 	State() states.Enum
 	ArticleChanged(*Article)
 	Move() Move
+	// RunInTerminal suspends the screen, runs the terminal program in the foreground with run
+	// and resumes the screen
+	RunInTerminal(run func() error) error
 }
 
 // used for moving the selected card
@@ -73,7 +107,36 @@ func New(ctx context.Context, cb Callbacks, paths []string, options ...Option) (
 		return nil, fmt.Errorf("no feeds")
 	}
 	p.feedInputs = feedInputs
-	p.mediaExtractor = &media.Extractor{Client: p.httpClient}
+	p.offlineStore = offline.New(cacheDir("offline"))
+	p.siteConfigs = siteconfig.NewRegistry(configDir("siteconfig"))
+	hist, err := history.New(cacheDir("history.json"))
+	if err != nil {
+		log.Println("ERROR: loading history:", err)
+	}
+	p.history = hist
+	p.processes = media.NewRegistry()
+	p.processes.OnChange = p.onProcessesChange
+	p.mediaExtractor = &media.Extractor{
+		Client:    p.httpClient,
+		Processes: p.processes,
+		Terminal:  cb.RunInTerminal,
+	}
+	p.downloads, err = downloads.New(cacheDir("downloads.json"))
+	if err != nil {
+		log.Println("ERROR: loading downloads:", err)
+	}
+	p.downloads.Extension = func(contentType string) string {
+		if exts := extensionByType(contentType); len(exts) > 0 {
+			return exts[0]
+		}
+		return ""
+	}
+	p.downloads.OnEvent = p.onDownloadEvent
+	p.downloadWorkers = 3
+	// the player command is set by WithQueuePlayer
+	p.queue = media.NewQueue("")
+	p.queue.OnEvent = p.onQueueEvent
+	p.queue.Processes = p.processes
 	p.ImgDownloader = newImgDownloader(ctx, p.httpClient)
 	for _, o := range options {
 		o(p)
@@ -83,9 +146,11 @@ func New(ctx context.Context, cb Callbacks, paths []string, options ...Option) (
 	}
 	p.mediaExtractor.Client = p.httpClient
 	p.ImgDownloader.client = p.httpClient
+	p.downloads.Client = p.httpClient
 	if err := p.loadPlugins(); err != nil {
 		log.Fatal("ERROR:", err)
 	}
+	p.downloads.Start(ctx, p.downloadWorkers)
 	events.Emit(&events.Init{})
 	return p, nil
 }
@@ -104,6 +169,14 @@ func WithMediaExtractor(extractor string) Option {
 	}
 }
 
+// WithMediaJSONExtractor sets the extractor that prints the media info as json (yt-dlp -J),
+// it's used instead of the media extractor
+func WithMediaJSONExtractor(jsonCmd string) Option {
+	return func(p *Photon) {
+		p.mediaExtractor.JSONCmd = jsonCmd
+	}
+}
+
 func WithMediaVideoCmd(videoCmd string) Option {
 	return func(p *Photon) {
 		p.mediaExtractor.VideoCmd = videoCmd
@@ -122,6 +195,20 @@ func WithMediaTorrentCmd(torrentCmd string) Option {
 	}
 }
 
+// WithMediaHandlers adds the content-type handlers in the PATTERN[:PRIORITY]=COMMAND form
+func WithMediaHandlers(specs []string) Option {
+	return func(p *Photon) {
+		for _, spec := range specs {
+			h, err := media.ParseHandler(spec)
+			if err != nil {
+				log.Println("ERROR: media handler:", err)
+				continue
+			}
+			p.mediaExtractor.AddHandler(h)
+		}
+	}
+}
+
 func WithDownloadPath(downloadPath string) Option {
 	return func(p *Photon) {
 		p.downloadPath = downloadPath
@@ -150,11 +237,17 @@ func (p *Photon) loadFeeds(paths []string) *inputs.Inputs {
 			if err != nil {
 				log.Fatal("ERROR: opening file:", err)
 			}
-			feeds, err := inputs.Parse(f)
+			feeds, options, err := inputs.ParseWithOptions(f)
 			if err != nil {
 				f.Close()
 				log.Fatal("ERROR: parsing file:", err)
 			}
+			if p.feedOptions == nil {
+				p.feedOptions = make(inputs.Options)
+			}
+			for feed, o := range options {
+				p.feedOptions[feed] = o
+			}
 			f.Close()
 			ret = append(ret, feeds...)
 		}
@@ -169,33 +262,34 @@ func (p *Photon) SearchQuery(q string) {
 
 func (p *Photon) DownloadFeeds() {
 	p.Cards = nil
-	feeds := make(chan *gofeed.Feed)
+	// the feeds are sent with the input they are loaded from
+	type loadedFeed struct {
+		input string
+		feed  *gofeed.Feed
+	}
+	feeds := make(chan loadedFeed)
 	for _, feedURL := range *p.feedInputs {
 		feedURL := feedURL
 		go func() {
-			fp := gofeed.NewParser()
-			fp.Client = p.httpClient
-			fp.AtomTranslator = newCustomAtomTranslator()
-			fp.RSSTranslator = newCustomRSSTranslator()
+			fp := p.newFeedParser()
 			var err error
 			var f *gofeed.Feed
 			switch {
 			case feedURL == "-":
 				f, err = fp.Parse(os.Stdin)
 			case strings.HasPrefix(feedURL, "cmd://"):
-				var command []string
-				for _, c := range strings.Split(feedURL[6:], " ") {
-					c = strings.TrimSpace(c)
-					if c != "" {
-						command = append(command, c)
-					}
+				cmd, cmdErr := cmdline.Command(feedURL[6:], nil, nil)
+				if cmdErr != nil {
+					log.Printf("ERROR: feed command (%s): %s", feedURL, cmdErr)
+					feeds <- loadedFeed{input: feedURL}
+					return
 				}
-				cmd := exec.Command(command[0], command[1:]...) //nolint:gosec // we trust the user
 				var stdout bytes.Buffer
 				cmd.Stdout = &stdout
-				if err := cmd.Run(); err != nil {
+				info := media.ProcessInfo{Kind: media.ProcessFeed, Title: feedURL}
+				if err := p.processes.Run(cmd, info); err != nil {
 					log.Printf("ERROR: running command (%s): %s", feedURL, err)
-					feeds <- nil
+					feeds <- loadedFeed{input: feedURL}
 					return
 				}
 				f, err = fp.Parse(&stdout)
@@ -206,23 +300,31 @@ func (p *Photon) DownloadFeeds() {
 			}
 			if err != nil {
 				log.Printf("ERROR: downloading feed (%s): %s", feedURL, err)
-				feeds <- nil
+				feeds <- loadedFeed{input: feedURL}
 				return
 			}
-			feeds <- f
+			feeds <- loadedFeed{input: feedURL, feed: f}
 		}()
 	}
 	var (
 		feedsGot     int
+		feedsFailed  int
 		ticker       = time.NewTicker(time.Millisecond * 150)
 		spinnerIndex int
 	)
 	defer ticker.Stop()
 	for {
-		var f *gofeed.Feed
+		var (
+			f     *gofeed.Feed
+			input string
+		)
 		select {
-		case f = <-feeds:
+		case lf := <-feeds:
+			f, input = lf.feed, lf.input
 			feedsGot++
+			if f == nil {
+				feedsFailed++
+			}
 		case <-ticker.C:
 			spinnerIndex = (spinnerIndex + 1) % len(spinnerArray)
 		}
@@ -247,9 +349,11 @@ func (p *Photon) DownloadFeeds() {
 				photon:     p,
 				Item:       item,
 				Feed:       f,
+				FeedInput:  input,
 				Foreground: -1,
 				Background: -1,
 			}
+			newCards[i].offlineReady.Store(p.offlineStore.Has(item.Link))
 		}
 		p.Cards = append(p.Cards, newCards...)
 		if feedsGot == p.feedInputs.Len() {
@@ -258,9 +362,25 @@ func (p *Photon) DownloadFeeds() {
 		f = nil
 	}
 	p.SetStatus("")
-	sort.Sort(p.Cards)
+	p.sortCards()
 	p.filterCards()
 	events.Emit(&events.FeedsDownloaded{})
+	if p.offlineSync.enabled {
+		// the articles of the items, that aren't in the feeds anymore, are removed only when
+		// all the feeds were loaded, so a failed feed doesn't lose it's offline articles
+		go p.syncOffline(p.Cards, feedsFailed == 0)
+	}
+	if p.autoDownloader != nil && p.autoDownloader.enabled {
+		go p.autoDownload(p.Cards)
+	}
+}
+
+func (p *Photon) newFeedParser() *gofeed.Parser {
+	fp := gofeed.NewParser()
+	fp.Client = p.httpClient
+	fp.AtomTranslator = newCustomAtomTranslator()
+	fp.RSSTranslator = newCustomRSSTranslator()
+	return fp
 }
 
 func (p *Photon) filterCards() {
@@ -271,15 +391,24 @@ func (p *Photon) filterCards() {
 	}
 	p.VisibleCards = nil
 	for _, card := range p.Cards {
-		if strings.Contains(strings.ToLower(card.Item.Title), query) ||
-			strings.Contains(strings.ToLower(card.Item.Description), query) ||
-			strings.Contains(strings.ToLower(card.Feed.Title), query) ||
-			card.Item.Author != nil && strings.Contains(strings.ToLower(card.Item.Author.Name), query) {
+		if card.matches(query) {
 			p.VisibleCards = append(p.VisibleCards, card)
 		}
 	}
 }
 
+// cacheDir returns the path to the photon cache subdirectory
+func cacheDir(name string) string {
+	cache, _ := os.UserCacheDir()
+	return filepath.Join(cache, "photon", name)
+}
+
+// configDir returns the path to the photon config subdirectory
+func configDir(name string) string {
+	conf, _ := os.UserConfigDir()
+	return filepath.Join(conf, "photon", name)
+}
+
 func (p *Photon) GetStatus() string {
 	return p.status.text
 }
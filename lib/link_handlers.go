@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+	"git.sr.ht/~ghost08/photon/lib/events"
+	"git.sr.ht/~ghost08/photon/lib/media"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// the built-in actions of the link handlers
+const (
+	LinkBrowser = "browser"
+	LinkArticle = "article"
+	LinkMedia   = "media"
+)
+
+// LinkHandler opens the item links matching the host or the regexp with the action
+type LinkHandler struct {
+	// Host matches the link host and it's subdomains
+	Host   string
+	Regexp *regexp.Regexp
+	// Action is browser, article, media or a command template
+	Action string
+	// Open is the lua function of the handler added by a plugin, it's called instead of the action
+	Open func(*Card) error
+}
+
+// ParseLinkHandler parses a handler in the MATCH=ACTION form, the MATCH is a host (youtube.com)
+// or a regexp of the link after ~ (~\.pdf$), the ACTION is browser, article, media or a command
+func ParseLinkHandler(spec string) (LinkHandler, error) {
+	match, action, ok := strings.Cut(spec, "=")
+	action = strings.TrimSpace(action)
+	if !ok || action == "" {
+		return LinkHandler{}, fmt.Errorf("link handler `%s`: expected MATCH=ACTION", spec)
+	}
+	h, err := parseLinkMatch(match)
+	if err != nil {
+		return LinkHandler{}, fmt.Errorf("link handler `%s`: %w", spec, err)
+	}
+	h.Action = action
+	return h, nil
+}
+
+// parseLinkMatch parses the host or the ~regexp of the link handler
+func parseLinkMatch(match string) (LinkHandler, error) {
+	match = strings.TrimSpace(match)
+	if expr, ok := strings.CutPrefix(match, "~"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return LinkHandler{}, err
+		}
+		return LinkHandler{Regexp: re}, nil
+	}
+	if match == "" {
+		return LinkHandler{}, errors.New("empty host")
+	}
+	return LinkHandler{Host: strings.ToLower(strings.TrimPrefix(match, "www."))}, nil
+}
+
+// match reports if the handler matches the link
+func (h LinkHandler) match(link string) bool {
+	if h.Regexp != nil {
+		return h.Regexp.MatchString(link)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	return host == h.Host || strings.HasSuffix(host, "."+h.Host)
+}
+
+// WithLinkHandlers adds the link handlers in the MATCH=ACTION form, the first matching handler
+// opens the link
+func WithLinkHandlers(specs []string) Option {
+	return func(p *Photon) {
+		for _, spec := range specs {
+			h, err := ParseLinkHandler(spec)
+			if err != nil {
+				log.Println("ERROR:", err)
+				continue
+			}
+			p.linkHandlers = append(p.linkHandlers, h)
+		}
+	}
+}
+
+// OpenLink opens the card's link with the first matching link handler,
+// or with the action (browser, article), when no handler matches,
+// the lua handlers are skipped, when a lua handler opens the link with card:openLink
+func (card *Card) OpenLink(action string) error {
+	if card == nil {
+		return nil
+	}
+	inHandler := card.inLinkHandler.Load()
+	for _, h := range card.photon.linkHandlers {
+		if !h.match(card.Item.Link) {
+			continue
+		}
+		if h.Open != nil {
+			if inHandler {
+				continue
+			}
+			card.inLinkHandler.Store(true)
+			defer card.inLinkHandler.Store(false)
+			return h.Open(card)
+		}
+		action = h.Action
+		break
+	}
+	return card.openLinkWith(action)
+}
+
+// openLinkWith opens the card's link with the built-in action or the command
+func (card *Card) openLinkWith(action string) error {
+	switch action {
+	case LinkBrowser:
+		return card.OpenBrowser()
+	case LinkArticle:
+		card.OpenArticle(context.Background())
+		return nil
+	case LinkMedia:
+		card.RunMedia()
+		return nil
+	}
+	cmd, err := cmdline.Command(action, cmdline.Legacy{'%': "{url}"}, card.CommandVars())
+	if err != nil {
+		return fmt.Errorf("link handler: %w", err)
+	}
+	p, err := card.photon.processes.Start(cmd, media.ProcessInfo{
+		Kind:  media.ProcessLink,
+		Title: card.Item.Title,
+		Link:  card.Item.Link,
+	})
+	if err != nil {
+		return fmt.Errorf("starting link handler: %w", err)
+	}
+	events.Emit(&events.LinkOpened{
+		Link: card.Item.Link,
+		Card: newCardFunc(card),
+	})
+	go func() {
+		if err := p.Wait(); err != nil {
+			log.Printf("ERROR: link handler (%s): %s", card.Item.Link, err)
+			card.photon.StatusWithTimeout(fmt.Sprintf("ERROR: link handler: %s", err), time.Second*3)
+		}
+	}()
+	return nil
+}
+
+// addLinkHandler adds a link handler, the action is browser, article, media, a command
+// or a lua function, that gets the card
+func (p *Photon) addLinkHandler(L *lua.LState) int {
+	h, err := parseLinkMatch(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+		return 0
+	}
+	switch action := L.CheckAny(2).(type) {
+	case *lua.LFunction:
+		h.Open = func(card *Card) error {
+			L.Push(action)
+			L.Push(newCard(card, L))
+			return L.PCall(1, 0, nil)
+		}
+	case lua.LString:
+		h.Action = string(action)
+	default:
+		L.ArgError(2, "expected a action string or a function")
+		return 0
+	}
+	p.linkHandlers = append(p.linkHandlers, h)
+	return 0
+}
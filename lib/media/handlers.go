@@ -0,0 +1,118 @@
+package media
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Handler is the command that opens the media of a content-type
+type Handler struct {
+	// Pattern is the content-type the handler matches, it can contain wildcards (video/*, */*, *mpegurl)
+	Pattern string
+	// Command is the command template run with the media (see the cmdline package),
+	// without a {media}, {url}, {file} or {audio} placeholder the media data is piped to it's stdin
+	Command string
+	// Priority decides between the handlers that match the same content-type, the highest wins
+	Priority int
+	// Terminal handlers are terminal programs, photon suspends the screen and runs them in the foreground
+	Terminal bool
+}
+
+// ParseHandler parses a handler in the PATTERN[:PRIORITY][:terminal]=COMMAND form
+func ParseHandler(spec string) (Handler, error) {
+	pattern, command, ok := strings.Cut(spec, "=")
+	pattern, command = strings.TrimSpace(pattern), strings.TrimSpace(command)
+	if !ok || pattern == "" || command == "" {
+		return Handler{}, fmt.Errorf("handler `%s`: expected PATTERN[:PRIORITY][:terminal]=COMMAND", spec)
+	}
+	h := Handler{Pattern: pattern, Command: command}
+	if p, ok := strings.CutSuffix(pattern, ":terminal"); ok {
+		pattern, h.Pattern, h.Terminal = p, p, true
+	}
+	if i := strings.LastIndex(pattern, ":"); i >= 0 {
+		priority, err := strconv.Atoi(pattern[i+1:])
+		if err != nil {
+			return Handler{}, fmt.Errorf("handler `%s`: parsing priority: %w", spec, err)
+		}
+		h.Pattern, h.Priority = pattern[:i], priority
+	}
+	return h, nil
+}
+
+// AddHandler adds the handler to the handler table,
+// it takes precedence over the handlers with the same pattern and priority that were added before
+func (e *Extractor) AddHandler(h Handler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Handlers = append(e.Handlers, h)
+}
+
+// defaultHandlers are the handlers made from the VideoCmd, ImageCmd and TorrentCmd
+func (e *Extractor) defaultHandlers() []Handler {
+	var handlers []Handler
+	add := func(command string, terminal bool, patterns ...string) {
+		if command = strings.TrimSpace(command); command == "" {
+			return
+		}
+		for _, pattern := range patterns {
+			handlers = append(handlers, Handler{Pattern: pattern, Command: command, Terminal: terminal})
+		}
+	}
+	add(e.VideoCmd, e.VideoTerminal, "video/*", "audio/*", "image/gif", "*mpegurl")
+	add(e.ImageCmd, e.ImageTerminal, "image/*")
+	add(e.TorrentCmd, e.TorrentTerminal, "application/x-bittorrent", "magnet-link")
+	return handlers
+}
+
+// handler returns the handler of the content-type, the handler with the highest priority wins,
+// then the one with the more specific pattern and then the one added last
+func (e *Extractor) handler(contentType string) (Handler, bool) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	e.mu.Lock()
+	handlers := append(e.defaultHandlers(), e.Handlers...)
+	e.mu.Unlock()
+	var (
+		best  Handler
+		found bool
+	)
+	for _, h := range handlers {
+		if !matchPattern(strings.ToLower(h.Pattern), contentType) {
+			continue
+		}
+		if found && (h.Priority < best.Priority ||
+			h.Priority == best.Priority && specificity(h.Pattern) < specificity(best.Pattern)) {
+			continue
+		}
+		best, found = h, true
+	}
+	return best, found
+}
+
+// matchPattern reports if s matches the pattern, * matches any characters (also /) and ? one character
+func matchPattern(pattern, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if matchPattern(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && matchPattern(pattern[1:], s[1:])
+	}
+	return s != "" && s[0] == pattern[0] && matchPattern(pattern[1:], s[1:])
+}
+
+// specificity is the number of the pattern's characters that aren't wildcards
+func specificity(pattern string) int {
+	return len(pattern) - strings.Count(pattern, "*") - strings.Count(pattern, "?")
+}
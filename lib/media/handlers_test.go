@@ -0,0 +1,98 @@
+package media
+
+import "testing"
+
+func TestParseHandler(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Handler
+		wantErr bool
+	}{
+		{spec: "audio/*=mpv --no-video %", want: Handler{Pattern: "audio/*", Command: "mpv --no-video %"}},
+		{spec: " application/pdf = zathura - ", want: Handler{Pattern: "application/pdf", Command: "zathura -"}},
+		{spec: "video/*:10=mpv {media}", want: Handler{Pattern: "video/*", Command: "mpv {media}", Priority: 10}},
+		{spec: "video/*:-1=mpv %", want: Handler{Pattern: "video/*", Command: "mpv %", Priority: -1}},
+		{spec: "image/*:terminal=chafa {file}", want: Handler{Pattern: "image/*", Command: "chafa {file}", Terminal: true}},
+		{spec: "image/*:5:terminal=chafa {file}", want: Handler{Pattern: "image/*", Command: "chafa {file}", Priority: 5, Terminal: true}},
+		// the command can have = in it
+		{spec: "video/*=mpv --vo=kitty %", want: Handler{Pattern: "video/*", Command: "mpv --vo=kitty %"}},
+		{spec: "video/*", wantErr: true},
+		{spec: "=mpv", wantErr: true},
+		{spec: "video/*=", wantErr: true},
+		{spec: "video/*:high=mpv", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHandler(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHandler(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseHandler(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"video/*", "video/mp4", true},
+		{"video/*", "video/", true},
+		{"video/*", "audio/mp4", false},
+		{"*mpegurl", "application/vnd.apple.mpegurl", true},
+		{"*mpegurl", "audio/x-mpegurl", true},
+		{"*", "anything/at-all", true},
+		{"image/?if", "image/gif", true},
+		{"image/?if", "image/if", false},
+		{"application/pdf", "application/pdf", true},
+		{"application/pdf", "application/pdfx", false},
+		{"*/*+xml", "application/atom+xml", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	e := &Extractor{VideoCmd: "mpv %", ImageCmd: "imv -", TorrentCmd: "webtorrent %"}
+	e.AddHandler(Handler{Pattern: "audio/*", Command: "mpv --no-video %"})
+	e.AddHandler(Handler{Pattern: "audio/*", Command: "cmus-remote %"})
+	e.AddHandler(Handler{Pattern: "*", Command: "xdg-open %", Priority: -1})
+	e.AddHandler(Handler{Pattern: "image/*", Command: "feh -", Priority: 1})
+	e.AddHandler(Handler{Pattern: "application/pdf", Command: "zathura -", Terminal: true})
+	tests := []struct {
+		contentType string
+		want        string
+		found       bool
+	}{
+		// the default handler
+		{"video/mp4", "mpv %", true},
+		{"application/vnd.apple.mpegurl", "mpv %", true},
+		// the handler added last wins with the same pattern and priority
+		{"audio/mpeg", "cmus-remote %", true},
+		// the content-type parameters are ignored, the case doesn't matter
+		{"Audio/MPEG; charset=binary", "cmus-remote %", true},
+		// the higher priority wins over the more specific pattern
+		{"image/gif", "feh -", true},
+		{"image/png", "feh -", true},
+		{"application/x-bittorrent", "webtorrent %", true},
+		{"application/pdf", "zathura -", true},
+		// the catch all handler with the lower priority
+		{"text/html", "xdg-open %", true},
+	}
+	for _, tt := range tests {
+		h, ok := e.handler(tt.contentType)
+		if ok != tt.found || h.Command != tt.want {
+			t.Errorf("handler(%q) = %q, %v, want %q, %v", tt.contentType, h.Command, ok, tt.want, tt.found)
+		}
+	}
+	if _, ok := (&Extractor{}).handler("video/mp4"); ok {
+		t.Error("handler without any handlers: want not found")
+	}
+}
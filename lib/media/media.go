@@ -5,19 +5,62 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
 )
 
 type Extractor struct {
 	ExtractorCmd string
-	VideoCmd     string
-	ImageCmd     string
-	TorrentCmd   string
-	Client       *http.Client
+	// JSONCmd is the extractor printing the media info as json (yt-dlp -J),
+	// if it's set it's used instead of the ExtractorCmd
+	JSONCmd string
+	// VideoCmd, ImageCmd and TorrentCmd are the default handlers
+	VideoCmd   string
+	ImageCmd   string
+	TorrentCmd string
+	// VideoTerminal, ImageTerminal and TorrentTerminal mark the default handlers as terminal programs
+	VideoTerminal   bool
+	ImageTerminal   bool
+	TorrentTerminal bool
+	// Terminal calls run of a terminal handler in the foreground, with the screen suspended
+	Terminal func(run func() error) error
+	// Handlers are the content-type handlers added by the user, see AddHandler
+	Handlers []Handler
+	Client   *http.Client
+	// Processes tracks the extractors and players
+	Processes *Registry
+	mu        sync.Mutex
+	// cache has the extracted media by the item link, with the extractions in progress
+	cache map[string]*extraction
+}
+
+// cacheTTL is how long the extracted media is cached, the direct links of the extractors
+// (googlevideo) expire after a few hours
+const cacheTTL = time.Hour
+
+type extraction struct {
+	done  chan struct{}
+	media *Media
+	err   error
+	at    time.Time
+}
+
+// result returns a copy of the extracted media, so the callers (SelectFormat) don't change the cached one
+func (ex *extraction) result() (*Media, error) {
+	if ex.err != nil {
+		return nil, ex.err
+	}
+	m := *ex.media
+	return &m, nil
 }
 
 type Media struct {
@@ -25,21 +68,105 @@ type Media struct {
 	OriginalLink string
 	Links        []string
 	ContentType  string
+	// Title and Feed are the item and feed titles, for the {title} and {feed} placeholders
+	Title string
+	Feed  string
+	// ItemLink is the link of the item the media is from
+	ItemLink string
+	// the info from the JSON extractor
+	Duration  time.Duration
+	Uploader  string
+	Thumbnail string
+	Chapters  []Chapter
+	Formats   []Format
+	// Format is the id of the selected format, empty for the default
+	Format string
+	// Start is the position the playback is resumed from, for the {start} placeholder
+	Start time.Duration
+
+	defaultLinks       []string
+	defaultContentType string
+	// extractedAt is when the media was extracted, zero for the direct media
+	extractedAt time.Time
+}
+
+// Expired reports if the extracted links may have expired, see cacheTTL
+func (m *Media) Expired() bool {
+	return !m.extractedAt.IsZero() && time.Since(m.extractedAt) > cacheTTL
 }
 
+// NewMedia extracts the media of the link, the result is cached for the cacheTTL,
+// so the extractor runs once for every link, even if NewMedia is called while it's running
 func (e *Extractor) NewMedia(ctx context.Context, link string) (*Media, error) {
+	e.mu.Lock()
+	if e.cache == nil {
+		e.cache = make(map[string]*extraction)
+	}
+	e.evictExpired()
+	if ex, ok := e.cache[link]; ok {
+		e.mu.Unlock()
+		select {
+		case <-ex.done:
+			return ex.result()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ex := &extraction{done: make(chan struct{}), at: time.Now()}
+	e.cache[link] = ex
+	e.mu.Unlock()
+
+	ex.media, ex.err = e.extract(ctx, link)
+	if ex.err != nil {
+		// failed extractions are tried again
+		e.mu.Lock()
+		delete(e.cache, link)
+		e.mu.Unlock()
+	} else {
+		ex.media.extractedAt = ex.at
+	}
+	close(ex.done)
+	return ex.result()
+}
+
+// evictExpired removes the finished extractions older than the cacheTTL, e.mu must be locked
+func (e *Extractor) evictExpired() {
+	for link, ex := range e.cache {
+		select {
+		case <-ex.done:
+			if time.Since(ex.at) > cacheTTL {
+				delete(e.cache, link)
+			}
+		default:
+		}
+	}
+}
+
+// DirectMedia creates the media of a direct media link (a podcast enclosure), without running the extractor
+func (e *Extractor) DirectMedia(link, contentType string) *Media {
+	return &Media{e: e, OriginalLink: link, Links: []string{link}, ContentType: contentType}
+}
+
+func (e *Extractor) extract(ctx context.Context, link string) (*Media, error) {
 	ct, err := e.getContentType(ctx, link)
 	if err != nil {
 		return nil, fmt.Errorf("media link - getting content-type: %w ", err)
 	}
-	// if link is a image, video, or torrent, don't run the extractor
-	if e.determineCommand(ct) != "" {
-		return &Media{e: e, OriginalLink: link, Links: []string{link}, ContentType: ct}, nil
+	// if there is a handler for the link, don't run the extractor,
+	// web pages always go through the extractor
+	if _, ok := e.handler(ct); ok && !isHTML(ct) {
+		return e.DirectMedia(link, ct), nil
+	}
+	if e.JSONCmd != "" {
+		return e.extractJSON(link)
+	}
+	cmd, err := cmdline.Command(e.ExtractorCmd, cmdline.Legacy{'%': "{url}"}, cmdline.Vars{"url": link})
+	if err != nil {
+		return nil, fmt.Errorf("extracting media link: %w", err)
 	}
-	cmd := strings.Split(strings.TrimSpace(strings.ReplaceAll(e.ExtractorCmd, "%", link)), " ")
-	output, err := exec.Command(cmd[0], cmd[1:]...).Output() //nolint:gosec // we trust the user
+	output, err := e.Processes.Output(cmd, ProcessInfo{Kind: ProcessExtractor, Link: link})
 	if err != nil {
-		return nil, fmt.Errorf("extracting media link [%s]: %w (%s)", link, err, string(output))
+		return nil, fmt.Errorf("extracting media link [%s]: %w", link, err)
 	}
 	outputLines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	var links []string
@@ -79,75 +206,67 @@ func (e *Extractor) getContentType(ctx context.Context, link string) (string, er
 	return contentType, nil
 }
 
-// determineCommand returns videoCmd or imgCmd by the content-type
-func (e *Extractor) determineCommand(contentType string) (command string) {
-	switch {
-	case strings.HasPrefix(contentType, "video/"), strings.HasPrefix(contentType, "audio/"), contentType == "image/gif", strings.HasSuffix(contentType, "mpegurl"):
-		command = e.VideoCmd
-	case strings.HasPrefix(contentType, "image/"):
-		command = e.ImageCmd
-	case contentType == "application/x-bittorrent", contentType == "magnet-link":
-		command = e.TorrentCmd
-	}
-	return strings.TrimSpace(command)
+func isHTML(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "application/xhtml+xml")
 }
 
 func (media *Media) Run(ctx context.Context) {
-	command := media.e.determineCommand(media.ContentType)
-	if command == "" {
-		log.Println("ERROR: could not determine content-type:", media.ContentType)
+	h, ok := media.e.handler(media.ContentType)
+	if !ok {
+		log.Println("ERROR: no handler for content-type:", media.ContentType)
 		return
 	}
-	// run command with downloaded torrent file
+	legacy := cmdline.Legacy{'%': "{media} --audio-file={audio}", '$': "{url}"}
 	if media.ContentType == "application/x-bittorrent" {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.Links[0], http.NoBody)
-		if err != nil {
-			log.Printf("ERROR: downloading torrent file - creating http request: %s", err)
-			return
-		}
-		resp, err := media.e.Client.Do(req)
-		if err != nil {
-			log.Printf("ERROR: downloading torrent file: %s", err)
-			return
+		// the torrent file is downloaded and it's path is substituted
+		legacy = cmdline.Legacy{'%': "{file}"}
+	}
+	t, err := cmdline.Parse(h.Command, legacy)
+	if err != nil {
+		log.Println("ERROR: media command:", err)
+		return
+	}
+	vars := cmdline.Vars{
+		"url":   media.OriginalLink,
+		"media": media.Links[0],
+		"title": media.Title,
+		"feed":  media.Feed,
+		// the format picked by the user, for commands that extract the media themselves (mpv --ytdl-format)
+		"format": media.Format,
+	}
+	if media.Start > 0 {
+		vars["start"] = strconv.Itoa(int(media.Start.Seconds()))
+	}
+	if len(media.Links) > 1 {
+		vars["audio"] = media.Links[1]
+	}
+	run := func(cmd *exec.Cmd) error {
+		return media.e.Processes.Run(cmd, media.processInfo())
+	}
+	if h.Terminal {
+		run = func(cmd *exec.Cmd) error {
+			return media.e.runInTerminal(cmd, media.processInfo())
 		}
-		defer resp.Body.Close()
-		f, err := os.CreateTemp("", "*.torrent")
+	}
+	// run command with the downloaded media file
+	if t.Uses("file") {
+		file, err := media.downloadTemp(ctx)
 		if err != nil {
-			log.Printf("ERROR: downloading torrent file - creating temp file: %s", err)
-			return
-		}
-		if _, err := io.Copy(f, resp.Body); err != nil {
-			log.Printf("ERROR: downloading torrent file - writing data to file: %s", err)
-			return
-		}
-		if err := f.Close(); err != nil {
-			log.Printf("ERROR: downloading torrent file - closing file: %s", err)
+			log.Println("ERROR: downloading media file:", err)
 			return
 		}
-		cmd := strings.Split(strings.ReplaceAll(command, "%", f.Name()), " ")
-		if err := exec.Command(cmd[0], cmd[1:]...).Run(); err != nil { //nolint:gosec // we trust the user
-			log.Printf("ERROR: running media command (%s): %s", strings.Join(cmd, " "), err)
-		}
-		return
+		defer os.Remove(file)
+		vars["file"] = file
 	}
-
 	// run command with the media link
-	if strings.Contains(command, "%") {
-		args := media.Links[0]
-		if len(media.Links) > 1 {
-			args = fmt.Sprintf("%s --audio-file=%s", media.Links[0], media.Links[1])
-		}
-		cmd := strings.Split(strings.ReplaceAll(command, "%", args), " ")
-		if err := exec.Command(cmd[0], cmd[1:]...).Run(); err != nil { //nolint:gosec // we trust the user
-			log.Printf("ERROR: running media command (%s): %s", strings.Join(cmd, " "), err)
+	if t.Uses("media") || t.Uses("url") || t.Uses("file") || t.Uses("audio") {
+		cmd, err := t.Command(vars)
+		if err != nil {
+			log.Printf("ERROR: media command (%s): %s", t, err)
+			return
 		}
-		return
-	}
-	// run command with the direct item link
-	if strings.Contains(command, "$") {
-		cmd := strings.Split(strings.ReplaceAll(command, "$", media.OriginalLink), " ")
-		if err := exec.Command(cmd[0], cmd[1:]...).Run(); err != nil { //nolint:gosec // we trust the user
-			log.Printf("ERROR: running media command (%s): %s", strings.Join(cmd, " "), err)
+		if err := run(cmd); err != nil {
+			log.Printf("ERROR: running media command (%s): %s", t, err)
 		}
 		return
 	}
@@ -162,10 +281,15 @@ func (media *Media) Run(ctx context.Context) {
 		log.Println("ERROR: sending GET request for media link:", err)
 		return
 	}
-	cmd := strings.Split(command, " ")
-	c := exec.Command(cmd[0], cmd[1:]...) //nolint:gosec // we trust the user
+	c, err := t.Command(vars)
+	if err != nil {
+		resp.Body.Close()
+		log.Printf("ERROR: media command (%s): %s", t, err)
+		return
+	}
 	stdin, err := c.StdinPipe()
 	if err != nil {
+		resp.Body.Close()
 		log.Println("ERROR: getting stdin of command:", err)
 		return
 	}
@@ -174,7 +298,63 @@ func (media *Media) Run(ctx context.Context) {
 		defer resp.Body.Close()
 		io.Copy(stdin, resp.Body)
 	}()
-	if err := c.Run(); err != nil {
-		log.Printf("ERROR: running media command (%s): %s", strings.Join(cmd, " "), err)
+	if err := run(c); err != nil {
+		log.Printf("ERROR: running media command (%s): %s", t, err)
+	}
+}
+
+// runInTerminal runs the command in the foreground on the terminal (see TTY), the piped stdin is kept,
+// the command is tracked in the process registry like the other players
+func (e *Extractor) runInTerminal(cmd *exec.Cmd, info ProcessInfo) error {
+	in, out := TTY()
+	if cmd.Stdin == nil {
+		cmd.Stdin = in
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+	run := func() error {
+		return e.Processes.Run(cmd, info)
+	}
+	if e.Terminal == nil {
+		return run()
+	}
+	return e.Terminal(run)
+}
+
+// processInfo describes the player of the media in the process registry
+func (media *Media) processInfo() ProcessInfo {
+	return ProcessInfo{Kind: ProcessPlayer, Title: media.Title, Link: media.ItemLink}
+}
+
+// downloadTemp downloads the media to a temporary file and returns it's path
+func (media *Media) downloadTemp(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.Links[0], http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("creating http request: %w", err)
+	}
+	resp, err := media.e.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ext := ""
+	if exts, _ := mime.ExtensionsByType(media.ContentType); len(exts) > 0 {
+		ext = exts[0]
+	}
+	if media.ContentType == "application/x-bittorrent" {
+		ext = ".torrent"
+	}
+	f, err := os.CreateTemp("", "photon-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("creating temp file: %w", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing data to file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("closing file: %w", err)
 	}
+	return f.Name(), nil
 }
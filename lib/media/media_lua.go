@@ -35,6 +35,15 @@ func mediaIndex(L *lua.LState) int {
 		return mediaLinks(media, L)
 	case "contentType":
 		return mediaContentType(media, L)
+	case "duration":
+		L.Push(lua.LNumber(media.Duration.Seconds()))
+		return 1
+	case "uploader":
+		L.Push(lua.LString(media.Uploader))
+		return 1
+	case "format":
+		L.Push(lua.LString(media.Format))
+		return 1
 	case "run":
 		media.Run(L.Context())
 	}
@@ -60,3 +69,27 @@ func mediaContentType(media *Media, L *lua.LState) int {
 	L.Push(lua.LString(media.ContentType))
 	return 1
 }
+
+// NewHandlersLValue returns the lua table for adding content-type handlers:
+// add(pattern, command[, priority]) and get(contentType), which returns the command of the content-type
+func NewHandlersLValue(L *lua.LState, e *Extractor) lua.LValue {
+	return L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"add": func(L *lua.LState) int {
+			e.AddHandler(Handler{
+				Pattern:  L.CheckString(1),
+				Command:  L.CheckString(2),
+				Priority: L.OptInt(3, 0),
+			})
+			return 0
+		},
+		"get": func(L *lua.LState) int {
+			h, ok := e.handler(L.CheckString(1))
+			if !ok {
+				L.Push(lua.LNil)
+				return 1
+			}
+			L.Push(lua.LString(h.Command))
+			return 1
+		},
+	})
+}
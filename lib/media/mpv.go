@@ -0,0 +1,166 @@
+package media
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// mpvStartTimeout is how long to wait for the mpv IPC socket
+const mpvStartTimeout = 10 * time.Second
+
+// mpv is a running mpv controlled through it's JSON IPC socket
+type mpv struct {
+	cmd  *exec.Cmd
+	conn net.Conn
+	// dir is the private directory of the socket
+	dir string
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan mpvMessage
+}
+
+// mpvMessage is a reply to a command or a event
+type mpvMessage struct {
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	Event     string          `json:"event"`
+	Name      string          `json:"name"`
+	Reason    string          `json:"reason"`
+}
+
+// startMPV runs the mpv command in idle mode with a IPC socket,
+// onEvent is called with the events from mpv and onExit after mpv exits
+func startMPV(args []string, procs *Registry, onEvent func(mpvMessage), onExit func()) (*mpv, error) {
+	// the socket is in a directory only the user can access (0700), so no one else can connect to it
+	// or put their own socket in it's place
+	dir, err := os.MkdirTemp(os.Getenv("XDG_RUNTIME_DIR"), "photon-mpv-")
+	if err != nil {
+		return nil, fmt.Errorf("creating mpv socket directory: %w", err)
+	}
+	socket := filepath.Join(dir, "mpv.sock")
+	args = append(args, "--idle=yes", "--input-ipc-server="+socket)
+	cmd := exec.Command(args[0], args[1:]...) //nolint:gosec // we trust the user
+	proc, err := procs.Start(cmd, ProcessInfo{Kind: ProcessQueue, Title: "playback queue"})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("starting mpv: %w", err)
+	}
+	exited := proc.Done()
+	var conn net.Conn
+	for deadline := time.Now().Add(mpvStartTimeout); ; {
+		conn, err = net.Dial("unix", socket)
+		if err == nil {
+			break
+		}
+		select {
+		case <-exited:
+			os.RemoveAll(dir)
+			return nil, errors.New("mpv exited before opening the IPC socket")
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("connecting to mpv: %w", err)
+		}
+	}
+	m := &mpv{
+		cmd:     cmd,
+		conn:    conn,
+		dir:     dir,
+		pending: make(map[int]chan mpvMessage),
+	}
+	// the events are handled in their own goroutine, so the handlers can send commands
+	events := make(chan mpvMessage, 256)
+	go func() {
+		for msg := range events {
+			onEvent(msg)
+		}
+		onExit()
+	}()
+	go m.read(events)
+	return m, nil
+}
+
+// read reads the replies and events until mpv closes the connection
+func (m *mpv) read(events chan<- mpvMessage) {
+	scanner := bufio.NewScanner(m.conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var msg mpvMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Event != "" {
+			events <- msg
+			continue
+		}
+		m.mu.Lock()
+		ch, ok := m.pending[msg.RequestID]
+		delete(m.pending, msg.RequestID)
+		m.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+	m.conn.Close()
+	os.RemoveAll(m.dir)
+	m.mu.Lock()
+	for id, ch := range m.pending {
+		close(ch)
+		delete(m.pending, id)
+	}
+	m.mu.Unlock()
+	close(events)
+}
+
+// command sends the command to mpv and waits for the reply
+func (m *mpv) command(args ...any) (json.RawMessage, error) {
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	ch := make(chan mpvMessage, 1)
+	m.pending[id] = ch
+	data, err := json.Marshal(map[string]any{"command": args, "request_id": id})
+	if err == nil {
+		_, err = m.conn.Write(append(data, '\n'))
+	}
+	if err != nil {
+		delete(m.pending, id)
+		m.mu.Unlock()
+		return nil, fmt.Errorf("mpv command %v: %w", args, err)
+	}
+	m.mu.Unlock()
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			return nil, errors.New("mpv exited")
+		}
+		if msg.Error != "success" {
+			return nil, fmt.Errorf("mpv command %v: %s", args, msg.Error)
+		}
+		return msg.Data, nil
+	case <-time.After(5 * time.Second):
+		m.mu.Lock()
+		delete(m.pending, id)
+		m.mu.Unlock()
+		return nil, fmt.Errorf("mpv command %v: timeout", args)
+	}
+}
+
+// quit closes mpv
+func (m *mpv) quit() {
+	if _, err := m.command("quit"); err != nil {
+		m.cmd.Process.Kill()
+	}
+}
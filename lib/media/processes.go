@@ -0,0 +1,341 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessKind is what the process is used for
+type ProcessKind string
+
+const (
+	ProcessPlayer    ProcessKind = "player"
+	ProcessExtractor ProcessKind = "extractor"
+	ProcessQueue     ProcessKind = "queue"
+	ProcessRenderer  ProcessKind = "renderer"
+	ProcessFeed      ProcessKind = "feed"
+	ProcessLink      ProcessKind = "link"
+)
+
+// ProcessStatus is the state of the process
+type ProcessStatus string
+
+const (
+	ProcessRunning ProcessStatus = "running"
+	ProcessFailed  ProcessStatus = "failed"
+)
+
+const (
+	// stderrLimit is the size of the end of the stderr kept for every process
+	stderrLimit = 8 << 10
+	// maxFailed is the number of failed processes kept in the registry
+	maxFailed = 20
+	// killGrace is how long a terminated process has to exit, before it's killed
+	killGrace = time.Second
+)
+
+// ProcessInfo describes the process in the registry
+type ProcessInfo struct {
+	Kind ProcessKind
+	// Title is the item title or the feed of the process
+	Title string
+	// Link is the item link, the process was started for
+	Link string
+}
+
+// Process is a command started by photon
+type Process struct {
+	ProcessInfo
+	ID        int
+	Command   string
+	Pid       int
+	StartedAt time.Time
+	Status    ProcessStatus
+	// Error is the exit error of a failed process
+	Error string
+
+	cmd    *exec.Cmd
+	stderr *tailBuffer
+	done   chan struct{}
+	err    error
+	// group is set, when the process runs in it's own process group
+	group bool
+}
+
+// Stderr returns the end of the process's stderr
+func (p *Process) Stderr() string {
+	return p.stderr.String()
+}
+
+// Registry tracks the processes started by photon, the running and the failed ones,
+// a nil registry runs the commands without tracking them
+type Registry struct {
+	// OnChange is called when a process starts, exits or is removed
+	OnChange func()
+
+	mu    sync.Mutex
+	next  int
+	procs map[int]*Process
+}
+
+func NewRegistry() *Registry {
+	return &Registry{procs: make(map[int]*Process)}
+}
+
+// Start starts the command and tracks it until it exits, it's stderr is captured
+// (and still written to the cmd.Stderr), except the terminal programs writing to the terminal
+func (r *Registry) Start(cmd *exec.Cmd, info ProcessInfo) (*Process, error) {
+	p := &Process{
+		ProcessInfo: info,
+		Command:     strings.Join(cmd.Args, " "),
+		StartedAt:   time.Now(),
+		Status:      ProcessRunning,
+		cmd:         cmd,
+		stderr:      &tailBuffer{limit: stderrLimit},
+		done:        make(chan struct{}),
+	}
+	// the terminal programs stay in the foreground process group of the terminal,
+	// the others get their own group, so their children are stopped with them
+	if !isTerminal(cmd.Stdin) && !isTerminal(cmd.Stdout) && !isTerminal(cmd.Stderr) {
+		setProcessGroup(cmd)
+		p.group = true
+	}
+	switch {
+	case isTerminal(cmd.Stderr):
+		// the terminal programs check if their output is a terminal, it isn't piped
+	case cmd.Stderr != nil:
+		w := io.MultiWriter(cmd.Stderr, p.stderr)
+		// the combined output stays combined, so it isn't written from two goroutines
+		if cmd.Stdout == cmd.Stderr {
+			cmd.Stdout = w
+		}
+		cmd.Stderr = w
+	default:
+		cmd.Stderr = p.stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p.Pid = cmd.Process.Pid
+	if r != nil {
+		r.mu.Lock()
+		r.next++
+		p.ID = r.next
+		r.procs[p.ID] = p
+		r.mu.Unlock()
+		r.changed()
+	}
+	go func() {
+		err := cmd.Wait()
+		if r != nil {
+			r.exited(p, err)
+		} else {
+			p.err = err
+		}
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Done is closed after the process exits
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the process to exit and returns the exit error, with the end of the stderr
+func (p *Process) Wait() error {
+	<-p.done
+	if p.err == nil {
+		return nil
+	}
+	if stderr := strings.TrimSpace(p.Stderr()); stderr != "" {
+		return fmt.Errorf("%w (%s)", p.err, lastLine(stderr))
+	}
+	return p.err
+}
+
+// Run runs the command and waits for it to exit, like cmd.Run
+func (r *Registry) Run(cmd *exec.Cmd, info ProcessInfo) error {
+	p, err := r.Start(cmd, info)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+// Output runs the command and returns it's stdout, like cmd.Output
+func (r *Registry) Output(cmd *exec.Cmd, info ProcessInfo) ([]byte, error) {
+	if cmd.Stdout != nil {
+		return nil, errors.New("stdout already set")
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := r.Run(cmd, info)
+	return stdout.Bytes(), err
+}
+
+// exited removes the process from the registry, a failed process is kept, so it's error is visible
+func (r *Registry) exited(p *Process, err error) {
+	r.mu.Lock()
+	p.err = err
+	_, tracked := r.procs[p.ID]
+	switch {
+	case !tracked:
+	case err == nil:
+		delete(r.procs, p.ID)
+	default:
+		p.Status = ProcessFailed
+		p.Error = err.Error()
+		r.removeOldFailed()
+	}
+	r.mu.Unlock()
+	r.changed()
+}
+
+// removeOldFailed removes the oldest failed processes over the maxFailed
+func (r *Registry) removeOldFailed() {
+	var failed []*Process
+	for _, p := range r.procs {
+		if p.Status == ProcessFailed {
+			failed = append(failed, p)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].ID < failed[j].ID })
+	for i := 0; i < len(failed)-maxFailed; i++ {
+		delete(r.procs, failed[i].ID)
+	}
+}
+
+// List returns the processes sorted by the start time
+func (r *Registry) List() []Process {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Process, 0, len(r.procs))
+	for _, p := range r.procs {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Running returns the number of running processes
+func (r *Registry) Running() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int
+	for _, p := range r.procs {
+		if p.Status == ProcessRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// stop terminates the process and kills it, if it doesn't exit in the killGrace
+func (p *Process) stop() {
+	p.terminate()
+	select {
+	case <-p.done:
+	case <-time.After(killGrace):
+		p.kill()
+	}
+}
+
+// Kill stops the running process (see stop), or removes the failed process from the registry
+func (r *Registry) Kill(id int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	p, ok := r.procs[id]
+	running := ok && p.Status == ProcessRunning
+	delete(r.procs, id)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	if running {
+		go p.stop()
+	}
+	r.changed()
+}
+
+// KillAll terminates all the running processes and waits until they exit,
+// the processes still running after the killGrace are killed
+func (r *Registry) KillAll() {
+	if r == nil {
+		return
+	}
+	var running []*Process
+	r.mu.Lock()
+	for _, p := range r.procs {
+		if p.Status == ProcessRunning {
+			running = append(running, p)
+		}
+	}
+	r.mu.Unlock()
+	for _, p := range running {
+		p.terminate()
+	}
+	timeout := time.After(killGrace)
+	for i, p := range running {
+		select {
+		case <-p.done:
+		case <-timeout:
+			for _, p := range running[i:] {
+				p.kill()
+			}
+			return
+		}
+	}
+}
+
+func (r *Registry) changed() {
+	if r.OnChange != nil {
+		r.OnChange()
+	}
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = append(b.data[:0], b.data[len(b.data)-b.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+// lastLine returns the last line of the text
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[i+1:])
+	}
+	return s
+}
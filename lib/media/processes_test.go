@@ -0,0 +1,78 @@
+//go:build !windows
+
+package media
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKillAll(t *testing.T) {
+	r := NewRegistry()
+	// the first exits on SIGTERM, the second ignores it and is killed after the grace period,
+	// it's child is killed with it's process group
+	polite, err := r.Start(exec.Command("sleep", "10"), ProcessInfo{Kind: ProcessPlayer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubborn := exec.Command("sh", "-c", `trap "" TERM; sleep 10 & echo $!; wait`)
+	out, err := stubborn.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.Start(stubborn, ProcessInfo{Kind: ProcessPlayer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 32)
+	n, _ := out.Read(buf)
+	child, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		t.Fatalf("reading the child pid: %s", err)
+	}
+
+	start := time.Now()
+	r.KillAll()
+	for _, p := range []*Process{polite, p} {
+		select {
+		case <-p.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("%s is still running after KillAll", p.Command)
+		}
+	}
+	if d := time.Since(start); d < killGrace {
+		t.Errorf("KillAll returned after %s, before the grace period", d)
+	}
+	if err := polite.Wait(); err == nil || !strings.Contains(err.Error(), "terminated") {
+		t.Errorf("the polite process exit = %v, want terminated", err)
+	}
+	// the orphaned child is gone, or a zombie (when init doesn't reap it), shortly after the kill
+	deadline := time.Now().Add(time.Second)
+	for running(child) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if running(child) {
+		t.Errorf("the child %d of the killed process is still running", child)
+	}
+}
+
+// running reports if the process with the pid exists and isn't a zombie
+func running(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		// without procfs the existing process is taken as running
+		return !os.IsNotExist(err) || runtime.GOOS != "linux"
+	}
+	// the state is after the command in parentheses
+	_, state, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(state, "Z")
+}
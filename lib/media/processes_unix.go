@@ -0,0 +1,34 @@
+//go:build !windows
+
+package media
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in it's own process group, so the children of the command
+// are stopped with it
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signal sends the signal to the process group of the process, or to the process
+func (p *Process) signal(sig syscall.Signal) error {
+	if p.group {
+		return syscall.Kill(-p.Pid, sig)
+	}
+	return p.cmd.Process.Signal(sig)
+}
+
+// terminate asks the process to exit, the players save their state on SIGTERM
+func (p *Process) terminate() error {
+	return p.signal(syscall.SIGTERM)
+}
+
+func (p *Process) kill() error {
+	return p.signal(syscall.SIGKILL)
+}
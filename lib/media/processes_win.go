@@ -0,0 +1,16 @@
+//go:build windows
+
+package media
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills the process, windows has no SIGTERM
+func (p *Process) terminate() error {
+	return p.cmd.Process.Kill()
+}
+
+func (p *Process) kill() error {
+	return p.cmd.Process.Kill()
+}
@@ -0,0 +1,402 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+)
+
+// QueueItem is a item of the playback queue
+type QueueItem struct {
+	Title string
+	// URL is loaded by mpv, a page link is played through mpv's yt-dlp hook
+	URL string
+	// Format is the yt-dlp format of the item, empty for the default
+	Format string
+	// Start is the position the item is played from
+	Start time.Duration
+	// Value is the owner's data of the item (the card)
+	Value any
+}
+
+type QueueEventType int
+
+const (
+	// QueueChanged is sent when items are added, removed or moved
+	QueueChanged QueueEventType = iota
+	// QueueItemStarted is sent when mpv starts playing the item
+	QueueItemStarted
+	// QueueItemEnded is sent when the item played to it's end
+	QueueItemEnded
+	// QueueProgress is sent when the position, duration or pause state changes
+	QueueProgress
+)
+
+// QueueStatus is the state of the playback
+type QueueStatus struct {
+	Item *QueueItem
+	// Index is the index of the item in the queue, -1 if nothing is playing
+	Index    int
+	Len      int
+	Position time.Duration
+	Duration time.Duration
+	Paused   bool
+}
+
+// Queue is the playback queue, it's played by one long running mpv controlled through it's IPC socket
+type Queue struct {
+	// PlayerCmd is the mpv command, photon adds the idle and IPC socket options
+	PlayerCmd string
+	// OnEvent is called when the queue changes, or a item starts or ends
+	OnEvent func(QueueEventType, *QueueItem)
+	// Processes tracks the mpv process
+	Processes *Registry
+
+	mu       sync.Mutex
+	items    []*QueueItem
+	current  int
+	paused   bool
+	position time.Duration
+	duration time.Duration
+	player   *mpv
+	// loadMu serializes the loading, so only one mpv is started
+	loadMu sync.Mutex
+	// loading is set while mpv loads the current item, the end of the previous item is ignored
+	loading bool
+}
+
+// NewQueue creates a empty queue played by the mpv command
+func NewQueue(playerCmd string) *Queue {
+	return &Queue{PlayerCmd: playerCmd, current: -1}
+}
+
+// Add adds the item to the end of the queue, if nothing is playing the item is played
+func (q *Queue) Add(item *QueueItem) {
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	// the item is made current under the same lock, so the next Add doesn't play it's item too
+	var play *QueueItem
+	if q.current < 0 {
+		play = q.playLocked(len(q.items) - 1)
+	}
+	q.mu.Unlock()
+	q.emit(QueueChanged, item)
+	if play != nil {
+		q.start(play)
+	}
+}
+
+// Items returns the items of the queue and the index of the playing item
+func (q *Queue) Items() ([]*QueueItem, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]*QueueItem(nil), q.items...), q.current
+}
+
+// Remove removes the item at index, if it's playing the next item is played
+func (q *Queue) Remove(index int) {
+	q.mu.Lock()
+	if index < 0 || index >= len(q.items) {
+		q.mu.Unlock()
+		return
+	}
+	item := q.items[index]
+	q.items = append(q.items[:index], q.items[index+1:]...)
+	playing := index == q.current
+	switch {
+	case playing:
+		q.current = -1
+	case index < q.current:
+		q.current--
+	}
+	q.mu.Unlock()
+	q.emit(QueueChanged, item)
+	if playing {
+		if index < q.Len() {
+			q.Play(index)
+			return
+		}
+		q.stop()
+	}
+}
+
+// Move moves the item at index i to the index j
+func (q *Queue) Move(i, j int) {
+	q.mu.Lock()
+	if i < 0 || j < 0 || i >= len(q.items) || j >= len(q.items) || i == j {
+		q.mu.Unlock()
+		return
+	}
+	item := q.items[i]
+	q.items = append(q.items[:i], q.items[i+1:]...)
+	q.items = append(q.items[:j], append([]*QueueItem{item}, q.items[j:]...)...)
+	switch {
+	case q.current == i:
+		q.current = j
+	case i < q.current && j >= q.current:
+		q.current--
+	case i > q.current && j <= q.current && q.current >= 0:
+		q.current++
+	}
+	q.mu.Unlock()
+	q.emit(QueueChanged, item)
+}
+
+// Len returns the number of items in the queue
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Status returns the playing item with it's position
+func (q *Queue) Status() QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QueueStatus{
+		Index:    q.current,
+		Len:      len(q.items),
+		Position: q.position,
+		Duration: q.duration,
+		Paused:   q.paused,
+	}
+	if q.current >= 0 {
+		s.Item = q.items[q.current]
+	}
+	return s
+}
+
+// Play plays the item at index, mpv is started if it isn't running
+func (q *Queue) Play(index int) {
+	q.mu.Lock()
+	item := q.playLocked(index)
+	q.mu.Unlock()
+	if item != nil {
+		q.start(item)
+	}
+}
+
+// playLocked makes the item at index the current one and returns it, nil if there isn't
+// a item at index, q.mu must be locked, the item is played by start after unlocking
+func (q *Queue) playLocked(index int) *QueueItem {
+	if index < 0 || index >= len(q.items) {
+		return nil
+	}
+	q.current = index
+	q.position, q.duration, q.paused, q.loading = 0, 0, false, true
+	return q.items[index]
+}
+
+// start loads the current item to mpv
+func (q *Queue) start(item *QueueItem) {
+	if err := q.load(item); err != nil {
+		log.Println("ERROR: queue - playing item:", err)
+		q.mu.Lock()
+		q.current, q.loading = -1, false
+		q.mu.Unlock()
+	}
+	q.emit(QueueChanged, item)
+}
+
+// Next plays the next item
+func (q *Queue) Next() {
+	q.mu.Lock()
+	next := q.current + 1
+	q.mu.Unlock()
+	if next < q.Len() {
+		q.Play(next)
+	}
+}
+
+// Prev plays the previous item
+func (q *Queue) Prev() {
+	q.mu.Lock()
+	prev := q.current - 1
+	q.mu.Unlock()
+	if prev >= 0 {
+		q.Play(prev)
+	}
+}
+
+// TogglePause pauses or resumes the playback
+func (q *Queue) TogglePause() {
+	if p := q.playerConn(); p != nil {
+		if _, err := p.command("cycle", "pause"); err != nil {
+			log.Println("ERROR: queue - pause:", err)
+		}
+	}
+}
+
+// Seek moves the playback position by d
+func (q *Queue) Seek(d time.Duration) {
+	if p := q.playerConn(); p != nil {
+		if _, err := p.command("seek", d.Seconds(), "relative"); err != nil {
+			log.Println("ERROR: queue - seek:", err)
+		}
+	}
+}
+
+// Close quits mpv
+func (q *Queue) Close() {
+	if p := q.playerConn(); p != nil {
+		p.quit()
+	}
+}
+
+func (q *Queue) playerConn() *mpv {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.player
+}
+
+// load loads the item in mpv, it's started if it isn't running
+func (q *Queue) load(item *QueueItem) error {
+	q.loadMu.Lock()
+	defer q.loadMu.Unlock()
+	p := q.playerConn()
+	if p == nil {
+		t, err := cmdline.Parse(q.PlayerCmd, nil)
+		if err != nil {
+			return err
+		}
+		args := t.Args(nil)
+		if len(args) == 0 {
+			return errors.New("empty player command")
+		}
+		if p, err = startMPV(args, q.Processes, q.onEvent, q.onExit); err != nil {
+			return err
+		}
+		q.mu.Lock()
+		q.player = p
+		q.mu.Unlock()
+		for _, prop := range []string{"time-pos", "duration", "pause"} {
+			if _, err := p.command("observe_property", 1, prop); err != nil {
+				return err
+			}
+		}
+	}
+	format := item.Format
+	if format == "" {
+		format = "bestvideo+bestaudio/best"
+	}
+	if _, err := p.command("set_property", "ytdl-format", format); err != nil {
+		return err
+	}
+	if _, err := p.command("set_property", "force-media-title", item.Title); err != nil {
+		return err
+	}
+	start := "none"
+	if item.Start > 0 {
+		start = fmt.Sprintf("+%d", int(item.Start.Seconds()))
+	}
+	if _, err := p.command("set_property", "start", start); err != nil {
+		return err
+	}
+	if _, err := p.command("loadfile", item.URL, "replace"); err != nil {
+		return fmt.Errorf("loading %s: %w", item.URL, err)
+	}
+	if _, err := p.command("set_property", "pause", false); err != nil {
+		return err
+	}
+	return nil
+}
+
+// stop stops the playback, mpv keeps running
+func (q *Queue) stop() {
+	if p := q.playerConn(); p != nil {
+		if _, err := p.command("stop"); err != nil {
+			log.Println("ERROR: queue - stop:", err)
+		}
+	}
+	q.mu.Lock()
+	q.position, q.duration = 0, 0
+	q.mu.Unlock()
+}
+
+func (q *Queue) onEvent(msg mpvMessage) {
+	switch msg.Event {
+	case "property-change":
+		q.mu.Lock()
+		switch msg.Name {
+		case "time-pos", "duration":
+			var seconds float64
+			json.Unmarshal(msg.Data, &seconds)
+			d := time.Duration(seconds * float64(time.Second))
+			if msg.Name == "duration" {
+				q.duration = d
+				break
+			}
+			// time-pos changes with every frame, the status shows only seconds
+			if d.Truncate(time.Second) == q.position.Truncate(time.Second) {
+				q.position = d
+				q.mu.Unlock()
+				return
+			}
+			q.position = d
+		case "pause":
+			json.Unmarshal(msg.Data, &q.paused)
+		}
+		q.mu.Unlock()
+		q.emit(QueueProgress, nil)
+	case "start-file":
+		q.mu.Lock()
+		q.loading = false
+		var item *QueueItem
+		if q.current >= 0 {
+			item = q.items[q.current]
+		}
+		q.mu.Unlock()
+		if item != nil {
+			q.emit(QueueItemStarted, item)
+		}
+	case "end-file":
+		q.mu.Lock()
+		// a item replaced by loadfile ends with the stop reason
+		if q.loading || msg.Reason == "stop" || msg.Reason == "quit" || q.current < 0 {
+			q.mu.Unlock()
+			return
+		}
+		item := q.items[q.current]
+		q.mu.Unlock()
+		// a failed item didn't end, the next is played
+		if msg.Reason == "error" {
+			log.Printf("ERROR: queue - mpv failed playing %s", item.URL)
+		} else {
+			q.emit(QueueItemEnded, item)
+		}
+		q.mu.Lock()
+		next := q.current + 1
+		more := q.current >= 0 && next < len(q.items)
+		if !more {
+			q.current = -1
+			q.position, q.duration = 0, 0
+		}
+		q.mu.Unlock()
+		if more {
+			q.Play(next)
+			return
+		}
+		q.emit(QueueChanged, item)
+	}
+}
+
+func (q *Queue) onExit() {
+	q.mu.Lock()
+	q.player = nil
+	q.current, q.loading = -1, false
+	q.position, q.duration = 0, 0
+	q.mu.Unlock()
+	q.emit(QueueChanged, nil)
+}
+
+// emit calls the OnEvent callback
+func (q *Queue) emit(t QueueEventType, item *QueueItem) {
+	if q.OnEvent != nil {
+		q.OnEvent(t, item)
+	}
+}
@@ -0,0 +1,36 @@
+package media
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+var (
+	ttyOnce       sync.Once
+	ttyIn, ttyOut *os.File
+)
+
+// TTY returns the terminal the terminal programs run on, photon's stdout is /dev/null
+// or the log file, so the controlling terminal (/dev/tty) is opened, without it
+// the stdin and the stderr are used
+func TTY() (in, out *os.File) {
+	ttyOnce.Do(func() {
+		ttyIn, ttyOut = os.Stdin, os.Stderr
+		f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			log.Println("ERROR: opening the terminal:", err)
+			return
+		}
+		ttyIn, ttyOut = f, f
+	})
+	return ttyIn, ttyOut
+}
+
+// isTerminal reports if the stdin, stdout or stderr of a command is a terminal
+func isTerminal(stream any) bool {
+	f, ok := stream.(*os.File)
+	return ok && f != nil && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
@@ -0,0 +1,261 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+)
+
+// Format is a format of the media in the yt-dlp json output
+type Format struct {
+	ID string
+	// Note is the yt-dlp description of the format (720p, medium, ...)
+	Note   string
+	Ext    string
+	Width  int
+	Height int
+	FPS    float64
+	VCodec string
+	ACodec string
+	// Bitrate is the total bitrate in kbit/s
+	Bitrate float64
+	// Size in bytes, 0 if it's unknown
+	Size int64
+	URL  string
+	// Protocol is the yt-dlp download protocol (https, m3u8_native, ...)
+	Protocol string
+}
+
+// AudioOnly reports if the format has no video
+func (f Format) AudioOnly() bool {
+	return f.VCodec == "none" && f.ACodec != "none"
+}
+
+// VideoOnly reports if the format has no audio
+func (f Format) VideoOnly() bool {
+	return f.ACodec == "none" && f.VCodec != "none"
+}
+
+// contentType guesses the content-type of the format by it's protocol and extension
+func (f Format) contentType() string {
+	if strings.HasPrefix(f.Protocol, "m3u8") {
+		return "application/vnd.apple.mpegurl"
+	}
+	ext := strings.ToLower(f.Ext)
+	if f.AudioOnly() {
+		switch ext {
+		case "m4a":
+			return "audio/mp4"
+		case "mp3":
+			return "audio/mpeg"
+		}
+		return "audio/" + ext
+	}
+	if ct := mime.TypeByExtension("." + ext); ct != "" {
+		return ct
+	}
+	return "video/" + ext
+}
+
+// Chapter is a chapter of the media
+type Chapter struct {
+	Title      string
+	Start, End time.Duration
+}
+
+type ytdlpFormat struct {
+	FormatID   string  `json:"format_id"`
+	FormatNote string  `json:"format_note"`
+	Ext        string  `json:"ext"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	FPS        float64 `json:"fps"`
+	VCodec     string  `json:"vcodec"`
+	ACodec     string  `json:"acodec"`
+	TBR        float64 `json:"tbr"`
+	Filesize   int64   `json:"filesize"`
+	FilesizeAp int64   `json:"filesize_approx"`
+	URL        string  `json:"url"`
+	Protocol   string  `json:"protocol"`
+}
+
+type ytdlpInfo struct {
+	Type             string        `json:"_type"`
+	Entries          []ytdlpInfo   `json:"entries"`
+	Duration         float64       `json:"duration"`
+	Uploader         string        `json:"uploader"`
+	Thumbnail        string        `json:"thumbnail"`
+	Formats          []ytdlpFormat `json:"formats"`
+	RequestedFormats []ytdlpFormat `json:"requested_formats"`
+	ytdlpFormat
+	Chapters []struct {
+		Title     string  `json:"title"`
+		StartTime float64 `json:"start_time"`
+		EndTime   float64 `json:"end_time"`
+	} `json:"chapters"`
+}
+
+// extractJSON runs the JSON extractor (yt-dlp -J) and creates the media from it's output
+func (e *Extractor) extractJSON(link string) (*Media, error) {
+	cmd, err := cmdline.Command(e.JSONCmd, cmdline.Legacy{'%': "{url}"}, cmdline.Vars{"url": link})
+	if err != nil {
+		return nil, fmt.Errorf("extracting media info: %w", err)
+	}
+	output, err := e.Processes.Output(cmd, ProcessInfo{Kind: ProcessExtractor, Link: link})
+	if err != nil {
+		return nil, fmt.Errorf("extracting media info [%s]: %w", link, err)
+	}
+	var info ytdlpInfo
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("parsing media info: %w", err)
+	}
+	// a playlist is played from it's first entry
+	if info.Type == "playlist" {
+		if len(info.Entries) == 0 {
+			return nil, errors.New("extracting media info: empty playlist")
+		}
+		info = info.Entries[0]
+	}
+	m := &Media{
+		e:            e,
+		OriginalLink: link,
+		Duration:     time.Duration(info.Duration * float64(time.Second)),
+		Uploader:     info.Uploader,
+		Thumbnail:    info.Thumbnail,
+	}
+	for _, c := range info.Chapters {
+		m.Chapters = append(m.Chapters, Chapter{
+			Title: c.Title,
+			Start: time.Duration(c.StartTime * float64(time.Second)),
+			End:   time.Duration(c.EndTime * float64(time.Second)),
+		})
+	}
+	for _, f := range info.Formats {
+		if f.URL == "" {
+			continue
+		}
+		m.Formats = append(m.Formats, newFormat(f))
+	}
+	// the formats yt-dlp would download, video + audio or a single format
+	var requested []Format
+	for _, f := range info.RequestedFormats {
+		requested = append(requested, newFormat(f))
+	}
+	if len(requested) == 0 && info.URL != "" {
+		requested = append(requested, newFormat(info.ytdlpFormat))
+	}
+	if len(requested) == 0 {
+		return nil, errors.New("extracting media info: no links extracted")
+	}
+	m.setFormats(requested)
+	m.defaultLinks, m.defaultContentType, m.Format = m.Links, m.ContentType, ""
+	return m, nil
+}
+
+func newFormat(f ytdlpFormat) Format {
+	size := f.Filesize
+	if size == 0 {
+		size = f.FilesizeAp
+	}
+	return Format{
+		ID:       f.FormatID,
+		Note:     f.FormatNote,
+		Ext:      f.Ext,
+		Width:    f.Width,
+		Height:   f.Height,
+		FPS:      f.FPS,
+		VCodec:   f.VCodec,
+		ACodec:   f.ACodec,
+		Bitrate:  f.TBR,
+		Size:     size,
+		URL:      f.URL,
+		Protocol: f.Protocol,
+	}
+}
+
+// setFormats sets the links to the formats, the first is the played one and the second the audio
+func (m *Media) setFormats(formats []Format) {
+	m.Links = make([]string, 0, len(formats))
+	ids := make([]string, len(formats))
+	for i, f := range formats {
+		m.Links = append(m.Links, f.URL)
+		ids[i] = f.ID
+	}
+	m.ContentType = formats[0].contentType()
+	m.Format = strings.Join(ids, "+")
+}
+
+// SelectFormat plays the format with the id, video only formats are played with the best audio format,
+// a empty id selects the default formats
+func (m *Media) SelectFormat(id string) error {
+	if id == "" {
+		m.Links, m.ContentType, m.Format = m.defaultLinks, m.defaultContentType, ""
+		return nil
+	}
+	for _, f := range m.Formats {
+		if f.ID != id {
+			continue
+		}
+		formats := []Format{f}
+		if audio, ok := m.bestAudio(); ok && f.VideoOnly() {
+			formats = append(formats, audio)
+		}
+		m.setFormats(formats)
+		return nil
+	}
+	return fmt.Errorf("unknown format: %s", id)
+}
+
+// bestAudio returns the audio only format with the highest bitrate
+func (m *Media) bestAudio() (Format, bool) {
+	var (
+		best  Format
+		found bool
+	)
+	for _, f := range m.Formats {
+		if f.AudioOnly() && (!found || f.Bitrate > best.Bitrate) {
+			best, found = f, true
+		}
+	}
+	return best, found
+}
+
+// SortedFormats returns the formats from the best, video formats by resolution and bitrate,
+// then the audio only formats
+func (m *Media) SortedFormats() []Format {
+	formats := make([]Format, 0, len(m.Formats))
+	for _, f := range m.Formats {
+		// storyboards and other formats without video and audio can't be played
+		if f.VCodec == "none" && f.ACodec == "none" {
+			continue
+		}
+		formats = append(formats, f)
+	}
+	sort.SliceStable(formats, func(i, j int) bool {
+		a, b := formats[i], formats[j]
+		if a.AudioOnly() != b.AudioOnly() {
+			return !a.AudioOnly()
+		}
+		if a.Height != b.Height {
+			return a.Height > b.Height
+		}
+		return a.Bitrate > b.Bitrate
+	})
+	return formats
+}
+
+// FormatDuration returns the duration as h:mm:ss or m:ss
+func FormatDuration(d time.Duration) string {
+	s := int(math.Round(d.Seconds()))
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"context"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~ghost08/photon/lib/media"
+	"github.com/mmcdole/gofeed/extensions"
+	"golang.org/x/net/html"
+)
+
+// MediaItem is a media element of the item,
+// a enclosure, media:content, thumbnail or a media link found in the article
+type MediaItem struct {
+	URL string
+	// Type is the mime type, if it's known
+	Type string
+	// Medium is image, video, audio, ...
+	Medium string
+	// Size in bytes, 0 if it's unknown
+	Size          int64
+	Width, Height int
+	// Source is where the media was found (enclosure, media:content, media:thumbnail, image, extractor, article)
+	Source string
+}
+
+// MediaItems returns all the media of the item, without duplicates
+func (card *Card) MediaItems() []MediaItem {
+	var items []MediaItem
+	seen := make(map[string]bool)
+	add := func(mi MediaItem) {
+		if mi.URL == "" || seen[mi.URL] {
+			return
+		}
+		seen[mi.URL] = true
+		if mi.Medium == "" {
+			mi.Medium, _, _ = strings.Cut(mi.Type, "/")
+		}
+		items = append(items, mi)
+	}
+	for _, e := range card.Item.Enclosures {
+		size, _ := strconv.ParseInt(e.Length, 10, 64)
+		add(MediaItem{URL: e.URL, Type: e.Type, Size: size, Source: "enclosure"})
+	}
+	if media, ok := card.Item.Extensions["media"]; ok {
+		exts := media["content"]
+		for _, group := range media["group"] {
+			exts = append(exts, group.Children["content"]...)
+		}
+		for _, ext := range exts {
+			add(mediaExtensionItem(ext, "media:content"))
+		}
+		thumbnails := media["thumbnail"]
+		for _, group := range media["group"] {
+			thumbnails = append(thumbnails, group.Children["thumbnail"]...)
+		}
+		for _, ext := range thumbnails {
+			mi := mediaExtensionItem(ext, "media:thumbnail")
+			mi.Medium = "image"
+			add(mi)
+		}
+	}
+	if card.Item.Image != nil {
+		add(MediaItem{URL: card.Item.Image.URL, Medium: "image", Source: "image"})
+	}
+	if m := card.LoadedMedia(); m != nil {
+		for _, link := range m.Links {
+			add(MediaItem{URL: link, Type: m.ContentType, Source: "extractor"})
+		}
+	}
+	if article := card.loadedArticle(); article != nil && article.Node != nil {
+		base, _ := url.Parse(card.Item.Link)
+		for _, mi := range articleMediaItems(article.Node, base) {
+			add(mi)
+		}
+	}
+	return items
+}
+
+// PlayMediaItem plays the media item with the handler of it's type, only the web pages
+// (embedded players) and the items of a unknown medium go through the extractor
+func (card *Card) PlayMediaItem(mi MediaItem) {
+	if card == nil {
+		return
+	}
+	card.runMedia(func() (*media.Media, error) {
+		var m *media.Media
+		if typ := mi.contentType(); typ == "" {
+			var err error
+			m, err = card.photon.mediaExtractor.NewMedia(context.TODO(), mi.URL)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			m = card.photon.mediaExtractor.DirectMedia(mi.URL, typ)
+		}
+		m.Title = card.Item.Title
+		m.ItemLink = card.Item.Link
+		if card.Feed != nil {
+			m.Feed = card.Feed.Title
+		}
+		return m, nil
+	})
+}
+
+// contentType returns the type the media item is played by, the type of the items without it is guessed
+// from the extension of the link, or it's the type of the medium (image/*), it's empty for the web
+// pages and the embeds
+func (mi MediaItem) contentType() string {
+	if mi.Type != "" {
+		if strings.HasPrefix(mi.Type, "text/html") {
+			return ""
+		}
+		return mi.Type
+	}
+	switch mi.Medium {
+	case "image", "video", "audio":
+	default:
+		return ""
+	}
+	if u, err := url.Parse(mi.URL); err == nil {
+		if typ := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(typ, mi.Medium+"/") {
+			return typ
+		}
+	}
+	return mi.Medium + "/*"
+}
+
+// DownloadMediaItem downloads the media item
+func (card *Card) DownloadMediaItem(mi MediaItem) {
+	if card == nil {
+		return
+	}
+	card.download([]string{mi.URL})
+}
+
+func mediaExtensionItem(ext ext.Extension, source string) MediaItem {
+	size, _ := strconv.ParseInt(ext.Attrs["fileSize"], 10, 64)
+	width, _ := strconv.Atoi(ext.Attrs["width"])
+	height, _ := strconv.Atoi(ext.Attrs["height"])
+	return MediaItem{
+		URL:    ext.Attrs["url"],
+		Type:   ext.Attrs["type"],
+		Medium: ext.Attrs["medium"],
+		Size:   size,
+		Width:  width,
+		Height: height,
+		Source: source,
+	}
+}
+
+// articleMediaItems finds the images, videos, audio and embedded players in the article
+func articleMediaItems(node *html.Node, base *url.URL) []MediaItem {
+	var items []MediaItem
+	var walk func(*html.Node, string)
+	walk = func(n *html.Node, parent string) {
+		if n.Type == html.ElementNode {
+			medium := ""
+			switch n.Data {
+			case "img":
+				medium = "image"
+			case "video", "audio":
+				medium = n.Data
+			case "source":
+				medium = parent
+			case "iframe", "embed":
+				medium = "embed"
+			}
+			if src := attr(n, "src"); medium != "" && src != "" {
+				if u, err := base.Parse(src); err == nil {
+					src = u.String()
+				}
+				width, _ := strconv.Atoi(attr(n, "width"))
+				height, _ := strconv.Atoi(attr(n, "height"))
+				items = append(items, MediaItem{
+					URL:    src,
+					Type:   attr(n, "type"),
+					Medium: medium,
+					Width:  width,
+					Height: height,
+					Source: "article",
+				})
+			}
+			if n.Data == "video" || n.Data == "audio" {
+				parent = n.Data
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, parent)
+		}
+	}
+	walk(node, "")
+	return items
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,208 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/imgproc"
+	"git.sr.ht/~ghost08/photon/lib/offline"
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+const offlineFetchTimeout = time.Second * 30
+
+// offlineSync are the settings and the state of the background article prefetching
+type offlineSync struct {
+	enabled bool
+	filter  string
+	workers int
+
+	// running is locked by the sync, so the syncs started by the refreshes don't overlap
+	running sync.Mutex
+}
+
+func WithOfflineSync(filter string, workers int) Option {
+	return func(p *Photon) {
+		p.offlineSync.enabled = true
+		p.offlineSync.filter = filter
+		p.offlineSync.workers = max(1, workers)
+	}
+}
+
+// syncOffline fetches the article and the top image of every new card
+// (that matches the offline filter) and stores them in the offline store,
+// with prune the articles of the items, that aren't in the cards or the history, are removed
+func (p *Photon) syncOffline(cards Cards, prune bool) {
+	if !p.offlineSync.running.TryLock() {
+		return
+	}
+	defer p.offlineSync.running.Unlock()
+	if prune {
+		p.pruneOffline(cards)
+	}
+	var toFetch Cards
+	for _, card := range cards {
+		if card.OfflineReady() || card.Item.Link == "" {
+			continue
+		}
+		if p.offlineSync.filter != "" && !card.matches(p.offlineSync.filter) {
+			continue
+		}
+		toFetch = append(toFetch, card)
+	}
+	if len(toFetch) == 0 {
+		return
+	}
+	var (
+		wg      sync.WaitGroup
+		done    atomic.Int32
+		failed  atomic.Int32
+		workers = make(chan struct{}, p.offlineSync.workers)
+	)
+	for _, card := range toFetch {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			if err := card.saveOffline(); err != nil {
+				log.Printf("ERROR: offline sync (%s): %s", card.Item.Link, err)
+				failed.Add(1)
+			}
+			p.SetStatus(fmt.Sprintf("Offline sync %d/%d", done.Add(1), len(toFetch)))
+		}()
+	}
+	wg.Wait()
+	if n := failed.Load(); n > 0 {
+		p.StatusWithTimeout(fmt.Sprintf("Offline sync done, %d failed", n), time.Second*3)
+		return
+	}
+	p.StatusWithTimeout("Offline sync done", time.Second*3)
+}
+
+// pruneOffline removes the offline articles of the items, that aren't in the cards or the history
+func (p *Photon) pruneOffline(cards Cards) {
+	keep := make([]string, 0, len(cards))
+	for _, card := range cards {
+		keep = append(keep, card.Item.Link)
+	}
+	for _, r := range p.history.Recent() {
+		keep = append(keep, r.Link)
+	}
+	n, err := p.offlineStore.Prune(keep)
+	if err != nil {
+		log.Println("ERROR: pruning offline store:", err)
+	}
+	if n > 0 {
+		log.Printf("INFO: removed %d offline articles", n)
+	}
+}
+
+// saveOffline scrapes the card's article and downloads the top image to the offline store
+func (card *Card) saveOffline() error {
+	ctx, cancel := context.WithTimeout(context.Background(), offlineFetchTimeout)
+	defer cancel()
+	article, err := newArticle(ctx, card, card.photon.httpClient)
+	if err != nil {
+		return fmt.Errorf("scraping article: %w", err)
+	}
+	imageURL := article.Image
+	if imageURL == "" && card.Item.Image != nil {
+		imageURL = card.Item.Image.URL
+	}
+	if imageURL != "" {
+		data, err := card.photon.fetchBytes(ctx, imageURL)
+		if err != nil {
+			log.Printf("ERROR: offline sync - downloading image (%s): %s", imageURL, err)
+		} else if err := card.photon.offlineStore.SaveImage(card.Item.Link, data); err != nil {
+			return fmt.Errorf("saving image: %w", err)
+		}
+	}
+	if err := card.photon.offlineStore.Save(offlineEntry(card.Item.Link, article.Article)); err != nil {
+		return fmt.Errorf("saving article: %w", err)
+	}
+	card.offlineReady.Store(true)
+	card.photon.cb.Redraw()
+	return nil
+}
+
+func (p *Photon) fetchBytes(ctx context.Context, link string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// loadOfflineArticle returns the article from the offline store
+func (card *Card) loadOfflineArticle() (*Article, error) {
+	e, err := card.photon.offlineStore.Load(card.Item.Link)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(strings.NewReader(e.Content))
+	if err != nil {
+		return nil, fmt.Errorf("parsing offline article content: %w", err)
+	}
+	article := &Article{
+		Article: &readability.Article{
+			Title:         e.Title,
+			Byline:        e.Byline,
+			Node:          doc,
+			Content:       e.Content,
+			TextContent:   e.TextContent,
+			Length:        e.Length,
+			Excerpt:       e.Excerpt,
+			SiteName:      e.SiteName,
+			Image:         e.Image,
+			Favicon:       e.Favicon,
+			Language:      e.Language,
+			PublishedTime: e.PublishedTime,
+		},
+		Card: card,
+	}
+	if data, err := card.photon.offlineStore.LoadImage(card.Item.Link); err == nil {
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			article.TopImage = imgproc.NewImageResizer(img)
+		}
+	}
+	return article, nil
+}
+
+func offlineEntry(link string, a *readability.Article) *offline.Entry {
+	return &offline.Entry{
+		Link:          link,
+		Title:         a.Title,
+		Byline:        a.Byline,
+		Content:       a.Content,
+		TextContent:   a.TextContent,
+		Length:        a.Length,
+		Excerpt:       a.Excerpt,
+		SiteName:      a.SiteName,
+		Image:         a.Image,
+		Favicon:       a.Favicon,
+		Language:      a.Language,
+		PublishedTime: a.PublishedTime,
+		FetchedAt:     time.Now(),
+	}
+}
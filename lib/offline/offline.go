@@ -0,0 +1,135 @@
+// Package offline stores scraped articles and their top images on disk,
+// so they can be read without a network connection
+package offline
+
+import (
+	"crypto/sha1" //nolint:gosec // used only for file names
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Store struct {
+	dir string
+}
+
+// Entry is the stored article, the Content is the html of the article
+type Entry struct {
+	Link          string
+	Title         string
+	Byline        string
+	Content       string
+	TextContent   string
+	Length        int
+	Excerpt       string
+	SiteName      string
+	Image         string
+	Favicon       string
+	Language      string
+	PublishedTime *time.Time
+	FetchedAt     time.Time
+}
+
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// name returns the file name of the link's files, without the extension
+func (s *Store) name(link string) string {
+	sum := sha1.Sum([]byte(link)) //nolint:gosec // used only for file names
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) path(link, ext string) string {
+	return filepath.Join(s.dir, s.name(link)+ext)
+}
+
+// Has reports if the article of the link is stored
+func (s *Store) Has(link string) bool {
+	_, err := os.Stat(s.path(link, ".json"))
+	return err == nil
+}
+
+func (s *Store) Load(link string) (*Entry, error) {
+	data, err := os.ReadFile(s.path(link, ".json"))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("decoding offline entry: %w", err)
+	}
+	return &e, nil
+}
+
+func (s *Store) Save(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding offline entry: %w", err)
+	}
+	return s.write(s.path(e.Link, ".json"), data)
+}
+
+// Prune removes the stored articles and images of the links, that aren't in keep,
+// and returns the number of removed articles
+func (s *Store) Prune(keep []string) (int, error) {
+	des, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, link := range keep {
+		kept[s.name(link)] = true
+	}
+	var removed int
+	for _, de := range des {
+		ext := filepath.Ext(de.Name())
+		if de.IsDir() || (ext != ".json" && ext != ".img") || kept[strings.TrimSuffix(de.Name(), ext)] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, de.Name())); err != nil {
+			return removed, err
+		}
+		if ext == ".json" {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// LoadImage returns the stored top image data of the article
+func (s *Store) LoadImage(link string) ([]byte, error) {
+	return os.ReadFile(s.path(link, ".img"))
+}
+
+func (s *Store) SaveImage(link string, data []byte) error {
+	return s.write(s.path(link, ".img"), data)
+}
+
+// write writes the file atomically, so a half written entry is never loaded
+func (s *Store) write(path string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
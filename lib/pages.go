@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~ghost08/photon/lib/siteconfig"
+	"github.com/antchfx/htmlquery"
+	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
+)
+
+func WithArticleMaxPages(maxPages int) Option {
+	return func(p *Photon) {
+		p.articleMaxPages = maxPages
+	}
+}
+
+// siteConfigMaxPages is the maximum number of pages followed by the next_page_link rules of the site configs
+const siteConfigMaxPages = 20
+
+// pageLimit returns the maximum number of the fetched pages of the article, the next_page_link rules
+// of the site config are followed even with --article-max-pages 1
+func (p *Photon) pageLimit(rules *siteconfig.Config) int {
+	if rules != nil && len(rules.NextPageLink) > 0 && p.articleMaxPages < siteConfigMaxPages {
+		return siteConfigMaxPages
+	}
+	return p.articleMaxPages
+}
+
+// nextPageText matches the text of "next page" links
+var nextPageText = regexp.MustCompile(`(?i)^(next|next page|weiter|nächste|nächste seite|suivant|page suivante|siguiente|próxima|successiva|następna|ďalej|další|далее|следующая)(\s*[›»→>]+)?$|^[›»→>]+$`)
+
+// nextPageLink finds the link to the next page of the article, from the site config (without the
+// other ways, when the site config has the next_page_link rule), <link rel="next">, <a rel="next"> (only if it's a page of the article, the blogs link the next post with it)
+// or from the pagination of the page (a "next" link or the number of the next page),
+// base is the url of the current page
+func nextPageLink(rules *siteconfig.Config, doc *html.Node, article, base *url.URL, pageNum int) string {
+	if rules != nil && len(rules.NextPageLink) > 0 {
+		return rules.FindNextPageLink(doc, base)
+	}
+	var relNext, textNext, numNext string
+	nextNum := strconv.Itoa(pageNum + 1)
+	var walk func(n *html.Node, inPagination bool)
+	walk = func(n *html.Node, inPagination bool) {
+		if n.Type == html.ElementNode {
+			if !inPagination && isPagination(n) {
+				inPagination = true
+			}
+			switch n.Data {
+			case "link", "a":
+				href := htmlquery.SelectAttr(n, "href")
+				if href == "" {
+					break
+				}
+				if relNext == "" && hasToken(htmlquery.SelectAttr(n, "rel"), "next") {
+					if u, err := base.Parse(href); err == nil && isArticlePage(article, u) {
+						relNext = href
+					}
+				}
+				if n.Data != "a" {
+					break
+				}
+				text := strings.Join(strings.Fields(htmlquery.InnerText(n)), " ")
+				class := strings.ToLower(htmlquery.SelectAttr(n, "class") + " " + htmlquery.SelectAttr(n, "aria-label"))
+				// the "next" links outside of the pagination are mostly links to the next post
+				if textNext == "" && inPagination && (nextPageText.MatchString(text) || strings.Contains(class, "next")) {
+					textNext = href
+				}
+				if numNext == "" && inPagination && text == nextNum {
+					numNext = href
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, inPagination)
+		}
+	}
+	walk(doc, false)
+	for _, link := range []string{relNext, textNext, numNext} {
+		if link == "" {
+			continue
+		}
+		u, err := base.Parse(link)
+		if err != nil || u.Host != base.Host || u.String() == base.String() {
+			continue
+		}
+		u.Fragment = ""
+		return u.String()
+	}
+	return ""
+}
+
+// pageSegment matches the path segments added to the article path for the next pages (/2, /page/2, /page-2)
+var pageSegment = regexp.MustCompile(`(?i)^/(page[/-]?)?\d+/?$`)
+
+// isArticlePage reports if the link is a page of the article, it has the article path
+// with a page segment added, or the article path with a page parameter
+func isArticlePage(article, link *url.URL) bool {
+	if link.Host != article.Host {
+		return false
+	}
+	path := strings.TrimSuffix(article.Path, "/")
+	if rest, ok := strings.CutPrefix(link.Path, path); ok && pageSegment.MatchString(rest) {
+		return true
+	}
+	if strings.TrimSuffix(link.Path, "/") != path {
+		return false
+	}
+	for key, values := range link.Query() {
+		key = strings.ToLower(key)
+		if key != "p" && !strings.Contains(key, "page") {
+			continue
+		}
+		for _, v := range values {
+			if _, err := strconv.Atoi(v); err == nil && v != article.Query().Get(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPagination reports if the element is a pagination container by it's class or id
+func isPagination(n *html.Node) bool {
+	attrs := strings.ToLower(htmlquery.SelectAttr(n, "class") + " " + htmlquery.SelectAttr(n, "id"))
+	for _, s := range []string{"pagination", "pager", "paging", "page-nav", "pagenav", "page-numbers"} {
+		if strings.Contains(attrs, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasToken(s, token string) bool {
+	for _, t := range strings.Fields(strings.ToLower(s)) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// appendPages fetches the following pages of the article starting with the next link,
+// and joins their content to the article, with a separator between the pages
+func appendPages(ctx context.Context, client *http.Client, rules *siteconfig.Config, a *readability.Article, uri *url.URL, next string, maxPages int) {
+	visited := map[string]bool{uri.String(): true}
+	contents := []string{a.Content}
+	texts := []string{a.TextContent}
+	for pageNum := 2; pageNum <= maxPages && next != "" && !visited[next]; pageNum++ {
+		visited[next] = true
+		pg, err := fetchPage(ctx, client, next)
+		if err != nil {
+			log.Printf("ERROR: fetching page %d of article: %s", pageNum, err)
+			break
+		}
+		if pg.doc == nil {
+			break
+		}
+		visited[pg.url.String()] = true
+		next = nextPageLink(rules, pg.doc, uri, pg.url, pageNum)
+		pa, err := extractArticle(ctx, client, rules, pg.doc, pg.url)
+		if err != nil {
+			log.Printf("ERROR: extracting page %d of article: %s", pageNum, err)
+			break
+		}
+		contents = append(contents, fmt.Sprintf("<hr><h2>Page %d</h2>", pageNum), pa.Content)
+		texts = append(texts, pa.TextContent)
+	}
+	if len(texts) == 1 {
+		return
+	}
+	content := "<div>" + strings.Join(contents, "\n") + "</div>"
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		log.Println("ERROR: parsing joined article pages:", err)
+		return
+	}
+	a.Node = doc
+	a.Content = content
+	a.TextContent = strings.Join(texts, "\n\n")
+	a.Length = len(a.TextContent)
+}
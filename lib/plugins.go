@@ -11,6 +11,7 @@ import (
 	"git.sr.ht/~ghost08/photon/lib/keybindings"
 	"git.sr.ht/~ghost08/photon/lib/ls"
 	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/siteconfig"
 	"git.sr.ht/~ghost08/photon/lib/states"
 	"github.com/cjoudrey/gluahttp"
 	lua "github.com/yuin/gopher-lua"
@@ -68,15 +69,16 @@ func (p *Photon) initLuaState() {
 	p.cardsLoader(p.luaState)
 	p.luaState.PreloadModule("photon", p.photonLoader)
 	p.luaState.PreloadModule("http", gluahttp.NewHttpModule(p.httpClient).Loader)
-	cache, _ := os.UserCacheDir()
-	os.MkdirAll(filepath.Join(cache, "photon"), 0o755)
-	localStorage = ls.New(filepath.Join(cache, "photon", "localStorage"))
+	os.MkdirAll(cacheDir(""), 0o755)
+	localStorage = ls.New(cacheDir("localStorage"))
 	p.luaState.PreloadModule("localStorage", localStorage.Loader)
 }
 
 func (p *Photon) photonLoader(L *lua.LState) int {
 	exports := map[string]lua.LGFunction{
-		"state": p.state,
+		"state":          p.state,
+		"addArticleMode": p.addArticleMode,
+		"addLinkHandler": p.addLinkHandler,
 	}
 	mod := L.SetFuncs(L.NewTable(), exports)
 
@@ -88,11 +90,22 @@ func (p *Photon) photonLoader(L *lua.LState) int {
 	L.SetField(mod, "events", events.New(L))
 	L.SetField(mod, "keybindings", keybindings.NewLValue(L, p.KeyBindings))
 	L.SetField(mod, "feedInputs", inputs.New(L, p.feedInputs))
+	L.SetField(mod, "siteConfig", siteconfig.NewLValue(L, p.siteConfigs))
+	L.SetField(mod, "mediaHandlers", media.NewHandlersLValue(L, p.mediaExtractor))
+	L.SetField(mod, "queue", p.newQueueLValue(L))
 
 	// constants
 	L.SetField(mod, "Normal", lua.LNumber(states.Normal))
 	L.SetField(mod, "Article", lua.LNumber(states.Article))
 	L.SetField(mod, "Search", lua.LNumber(states.Search))
+	L.SetField(mod, "ArticleSearch", lua.LNumber(states.ArticleSearch))
+	L.SetField(mod, "History", lua.LNumber(states.History))
+	L.SetField(mod, "Formats", lua.LNumber(states.Formats))
+	L.SetField(mod, "Queue", lua.LNumber(states.Queue))
+	L.SetField(mod, "Downloads", lua.LNumber(states.Downloads))
+	L.SetField(mod, "AutoDownload", lua.LNumber(states.AutoDownload))
+	L.SetField(mod, "MediaItems", lua.LNumber(states.MediaItems))
+	L.SetField(mod, "Processes", lua.LNumber(states.Processes))
 	for n, c := range []string{
 		"ColorBlack",
 		"ColorMaroon",
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/history"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	// positionSaveInterval is how often the playback position of the queue is saved
+	positionSaveInterval = 10 * time.Second
+	// resumeEndMargin is the part of the end of the media, that isn't resumed, it's played from the start
+	resumeEndMargin = 30 * time.Second
+)
+
+// AudioEnclosure returns the first audio enclosure of the item (the podcast episode),
+// enclosures without a type are recognized by the extension
+func (card *Card) AudioEnclosure() (*gofeed.Enclosure, bool) {
+	for _, e := range card.Item.Enclosures {
+		if e.URL == "" {
+			continue
+		}
+		if strings.HasPrefix(e.Type, "audio/") {
+			return e, true
+		}
+		if e.Type == "" && strings.HasPrefix(enclosureType(e), "audio/") {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+// enclosureType returns the type of the enclosure, or guesses it by the extension of the link
+func enclosureType(e *gofeed.Enclosure) string {
+	if e.Type != "" {
+		return e.Type
+	}
+	ext := strings.ToLower(path.Ext(strings.SplitN(e.URL, "?", 2)[0]))
+	switch ext {
+	case ".m4a":
+		return "audio/mp4"
+	case ".opus":
+		return "audio/ogg"
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// Episode returns the season and episode number of the itunes extension (S2E14, E14),
+// empty if the item isn't a numbered episode
+func (card *Card) Episode() string {
+	it := card.Item.ITunesExt
+	if it == nil || it.Episode == "" {
+		return ""
+	}
+	if it.Season != "" {
+		return fmt.Sprintf("S%sE%s", it.Season, it.Episode)
+	}
+	return "E" + it.Episode
+}
+
+// itunesDuration returns the itunes:duration of the item, 0 if it's missing
+func (card *Card) itunesDuration() time.Duration {
+	if card.Item.ITunesExt == nil {
+		return 0
+	}
+	return parseITunesDuration(card.Item.ITunesExt.Duration)
+}
+
+// parseITunesDuration parses the itunes:duration, it's in seconds or in the HH:MM:SS or MM:SS form
+func parseITunesDuration(s string) time.Duration {
+	var seconds float64
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// MediaProgress returns the saved playback position and duration of the media,
+// the position is 0 if the media wasn't played or it was played to the end
+func (card *Card) MediaProgress() (position, duration time.Duration) {
+	r, ok := card.HistoryRecord()
+	if !ok {
+		return 0, 0
+	}
+	return r.MediaPosition, r.MediaDuration
+}
+
+// resumePosition returns the position the media is played from,
+// media played almost to the end is played from the start
+func (card *Card) resumePosition() time.Duration {
+	position, duration := card.MediaProgress()
+	if duration > 0 && position > duration-resumeEndMargin {
+		return 0
+	}
+	return position
+}
+
+// saveMediaPosition stores the playback position of the media
+func (card *Card) saveMediaPosition(position, duration time.Duration) {
+	card.photon.history.Update(card.Item.Link, func(r *history.Record) {
+		r.MediaPosition = position
+		if duration > 0 {
+			r.MediaDuration = duration
+		}
+	})
+}
+
+// markPlayed marks the media as played to the end, the next time it's played from the start
+func (card *Card) markPlayed() {
+	card.photon.history.Update(card.Item.Link, func(r *history.Record) {
+		r.MediaPosition = 0
+		r.Read = true
+	})
+}
+
+// saveQueuePosition stores the position of the playing queue item,
+// it's saved every positionSaveInterval and when the playback is paused
+func (p *Photon) saveQueuePosition() {
+	st := p.queue.Status()
+	card := QueuedCard(st.Item)
+	if card == nil || st.Position <= 0 {
+		return
+	}
+	saved, _ := card.MediaProgress()
+	diff := st.Position - saved
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff < positionSaveInterval && !(st.Paused && diff > time.Second) {
+		return
+	}
+	card.saveMediaPosition(st.Position, st.Duration)
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"log"
+
+	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/states"
+)
+
+// the policies of the processes, that are running when photon exits
+const (
+	OnExitKill = "kill"
+	OnExitKeep = "keep"
+)
+
+// WithOnExit sets what happens with the running players and commands when photon exits,
+// kill kills them, keep leaves them running
+func WithOnExit(policy string) Option {
+	return func(p *Photon) {
+		p.onExit = policy
+	}
+}
+
+// Processes returns the registry of the players and commands started by photon
+func (p *Photon) Processes() *media.Registry {
+	return p.processes
+}
+
+// ProcessCard returns the card the process was started for, nil if it isn't loaded
+func (p *Photon) ProcessCard(proc media.Process) *Card {
+	if proc.Link == "" {
+		return nil
+	}
+	for _, card := range p.Cards {
+		if card.Item.Link == proc.Link {
+			return card
+		}
+	}
+	return nil
+}
+
+// onProcessesChange redraws the processes view
+func (p *Photon) onProcessesChange() {
+	if p.cb.State() == states.Processes {
+		p.cb.Redraw()
+	}
+}
+
+// Close closes the playback queue and kills the running processes, if the exit policy is kill
+func (p *Photon) Close() {
+	if err := p.history.Flush(); err != nil {
+		log.Println("ERROR: saving history:", err)
+	}
+	p.queue.Close()
+	if p.onExit != OnExitKeep {
+		p.processes.KillAll()
+	}
+}
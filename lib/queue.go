@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/events"
+	"git.sr.ht/~ghost08/photon/lib/history"
+	"git.sr.ht/~ghost08/photon/lib/media"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// WithQueuePlayer sets the mpv command that plays the queue
+func WithQueuePlayer(playerCmd string) Option {
+	return func(p *Photon) {
+		p.queue.PlayerCmd = playerCmd
+	}
+}
+
+// Queue returns the playback queue
+func (p *Photon) Queue() *media.Queue {
+	return p.queue
+}
+
+// onQueueEvent redraws the status bar and emits the queue events for the plugins
+func (p *Photon) onQueueEvent(t media.QueueEventType, item *media.QueueItem) {
+	card := QueuedCard(item)
+	switch t {
+	case media.QueueChanged:
+		events.Emit(&events.QueueChanged{})
+	case media.QueueItemStarted:
+		if card != nil {
+			card.addHistory(history.KindMedia)
+			events.Emit(&events.QueueItemStarted{
+				Link: card.Item.Link,
+				Card: newCardFunc(card),
+			})
+		}
+	case media.QueueProgress:
+		p.saveQueuePosition()
+	case media.QueueItemEnded:
+		if card != nil {
+			card.markPlayed()
+			events.Emit(&events.QueueItemEnded{
+				Link: card.Item.Link,
+				Card: newCardFunc(card),
+			})
+		}
+	}
+	p.cb.Redraw()
+}
+
+// Enqueue adds the card's media to the end of the playback queue
+func (card *Card) Enqueue() {
+	if card == nil {
+		return
+	}
+	item := &media.QueueItem{
+		Title: card.Item.Title,
+		URL:   card.Item.Link,
+		Start: card.resumePosition(),
+		Value: card,
+	}
+	if e, ok := card.AudioEnclosure(); ok {
+		item.URL = e.URL
+	} else if m := card.LoadedMedia(); m != nil {
+		item.Format = m.Format
+		// a extracted direct link is played without the yt-dlp hook
+		if m.Format == "" && len(m.Links) == 1 {
+			item.URL = m.Links[0]
+		}
+	}
+	card.photon.queue.Add(item)
+	card.photon.StatusWithTimeout(
+		fmt.Sprintf("Queued %s [%d]", card.Item.Title, card.photon.queue.Len()),
+		time.Second*3,
+	)
+}
+
+// QueuedCard returns the card of the queue item
+func QueuedCard(item *media.QueueItem) *Card {
+	if item == nil {
+		return nil
+	}
+	card, _ := item.Value.(*Card)
+	return card
+}
+
+func (p *Photon) newQueueLValue(L *lua.LState) lua.LValue {
+	exports := map[string]lua.LGFunction{
+		"len": func(L *lua.LState) int {
+			L.Push(lua.LNumber(p.queue.Len()))
+			return 1
+		},
+		"cards": func(L *lua.LState) int {
+			items, _ := p.queue.Items()
+			t := L.NewTable()
+			for _, item := range items {
+				if card := QueuedCard(item); card != nil {
+					t.Append(newCard(card, L))
+				}
+			}
+			L.Push(t)
+			return 1
+		},
+		"play": func(L *lua.LState) int {
+			// lua indexes from 1
+			p.queue.Play(L.CheckInt(1) - 1)
+			return 0
+		},
+		"remove": func(L *lua.LState) int {
+			p.queue.Remove(L.CheckInt(1) - 1)
+			return 0
+		},
+		"next": func(L *lua.LState) int {
+			p.queue.Next()
+			return 0
+		},
+		"prev": func(L *lua.LState) int {
+			p.queue.Prev()
+			return 0
+		},
+		"pause": func(L *lua.LState) int {
+			p.queue.TogglePause()
+			return 0
+		},
+		"seek": func(L *lua.LState) int {
+			p.queue.Seek(time.Duration(float64(L.CheckNumber(1)) * float64(time.Second)))
+			return 0
+		},
+	}
+	return L.SetFuncs(L.NewTable(), exports)
+}
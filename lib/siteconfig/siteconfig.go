@@ -0,0 +1,387 @@
+// Package siteconfig implements site specific article extraction rules,
+// the rule files are in the format of the FiveFilters ftr-site-config:
+//
+//	# comment
+//	title: //h1[@class='headline']
+//	body: article .story-body
+//	strip: //div[@class='related']
+//	strip_id_or_class: newsletter
+//	single_page_url: ^(https://example\.com/.*)$ -> $1?page=all
+//
+// selectors starting with / or ( are XPath expressions, the others are CSS selectors
+package siteconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// Config holds the extraction rules for one site
+type Config struct {
+	Title          []Selector
+	Body           []Selector
+	Author         []Selector
+	Image          []Selector
+	Strip          []Selector
+	StripIDOrClass []string
+	SinglePageLink []Selector
+	SinglePageURL  []Rewrite
+	NextPageLink   []Selector
+	// Prune runs readability on the extracted body
+	Prune bool
+	// AutodetectOnFailure falls back to readability when the body isn't found
+	AutodetectOnFailure bool
+}
+
+// Rewrite replaces the link matching the Pattern with the Replacement
+type Rewrite struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Selector finds nodes in a html document
+type Selector interface {
+	Find(*html.Node) []*html.Node
+}
+
+type xpathSelector struct {
+	expr *xpath.Expr
+}
+
+func (s xpathSelector) Find(doc *html.Node) []*html.Node {
+	return htmlquery.QuerySelectorAll(doc, s.expr)
+}
+
+type cssSelector struct {
+	sel cascadia.Sel
+}
+
+func (s cssSelector) Find(doc *html.Node) []*html.Node {
+	return cascadia.QueryAll(doc, s.sel)
+}
+
+// CompileSelector compiles the XPath expression or the CSS selector
+func CompileSelector(s string) (Selector, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, "(") {
+		expr, err := xpath.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("xpath `%s`: %w", s, err)
+		}
+		return xpathSelector{expr}, nil
+	}
+	sel, err := cascadia.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("css selector `%s`: %w", s, err)
+	}
+	return cssSelector{sel}, nil
+}
+
+// Parse reads the rules of one site
+func Parse(r io.Reader) (*Config, error) {
+	c := &Config{Prune: true, AutodetectOnFailure: true}
+	scanner := bufio.NewScanner(r)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		directive, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing ':'", lineNum)
+		}
+		directive = strings.TrimSpace(directive)
+		value = strings.TrimSpace(value)
+		if err := c.set(directive, value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) set(directive, value string) error {
+	var selectors *[]Selector
+	switch directive {
+	case "title":
+		selectors = &c.Title
+	case "body":
+		selectors = &c.Body
+	case "author":
+		selectors = &c.Author
+	case "image":
+		selectors = &c.Image
+	case "strip":
+		selectors = &c.Strip
+	case "single_page_link":
+		selectors = &c.SinglePageLink
+	case "next_page_link":
+		selectors = &c.NextPageLink
+	case "strip_id_or_class":
+		c.StripIDOrClass = append(c.StripIDOrClass, strings.Trim(value, `"'`))
+		return nil
+	case "single_page_url":
+		pattern, replacement, ok := strings.Cut(value, "->")
+		if !ok {
+			return fmt.Errorf("single_page_url: expected `PATTERN -> REPLACEMENT`")
+		}
+		re, err := regexp.Compile(strings.TrimSpace(pattern))
+		if err != nil {
+			return fmt.Errorf("single_page_url: %w", err)
+		}
+		c.SinglePageURL = append(c.SinglePageURL, Rewrite{re, strings.TrimSpace(replacement)})
+		return nil
+	case "prune":
+		c.Prune = value == "yes"
+		return nil
+	case "autodetect_on_failure":
+		c.AutodetectOnFailure = value == "yes"
+		return nil
+	default:
+		// other ftr-site-config directives (test_url, date, tidy, ...) are ignored
+		return nil
+	}
+	sel, err := CompileSelector(value)
+	if err != nil {
+		return err
+	}
+	*selectors = append(*selectors, sel)
+	return nil
+}
+
+// RewriteURL returns the single page version of the link
+func (c *Config) RewriteURL(link string) string {
+	for _, r := range c.SinglePageURL {
+		if r.Pattern.MatchString(link) {
+			return r.Pattern.ReplaceAllString(link, r.Replacement)
+		}
+	}
+	return link
+}
+
+// FindSinglePageLink returns the absolute link to the single page version of the document
+func (c *Config) FindSinglePageLink(doc *html.Node, base *url.URL) string {
+	return findLink(doc, base, c.SinglePageLink)
+}
+
+// FindNextPageLink returns the absolute link to the next page of the document
+func (c *Config) FindNextPageLink(doc *html.Node, base *url.URL) string {
+	return findLink(doc, base, c.NextPageLink)
+}
+
+// StripNodes removes the strip and strip_id_or_class elements from the document
+func (c *Config) StripNodes(doc *html.Node) {
+	var remove []*html.Node
+	for _, sel := range c.Strip {
+		remove = append(remove, sel.Find(doc)...)
+	}
+	if len(c.StripIDOrClass) > 0 {
+		var walk func(*html.Node)
+		walk = func(n *html.Node) {
+			if n.Type == html.ElementNode && c.matchesIDOrClass(n) {
+				remove = append(remove, n)
+				return
+			}
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+		}
+		walk(doc)
+	}
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func (c *Config) matchesIDOrClass(n *html.Node) bool {
+	for _, attr := range n.Attr {
+		if attr.Key != "id" && attr.Key != "class" {
+			continue
+		}
+		for _, s := range c.StripIDOrClass {
+			if strings.Contains(attr.Val, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FindBody returns the html of all the elements matched by the first matching body rule,
+// if no rule matches it returns an empty string
+func (c *Config) FindBody(doc *html.Node) (string, error) {
+	for _, sel := range c.Body {
+		nodes := sel.Find(doc)
+		if len(nodes) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		sb.WriteString("<div>")
+		for _, n := range nodes {
+			if err := html.Render(&sb, n); err != nil {
+				return "", err
+			}
+		}
+		sb.WriteString("</div>")
+		return sb.String(), nil
+	}
+	return "", nil
+}
+
+// FindTitle returns the text of the first element matched by the title rules
+func (c *Config) FindTitle(doc *html.Node) string {
+	return findText(doc, c.Title)
+}
+
+// FindAuthor returns the text of the first element matched by the author rules
+func (c *Config) FindAuthor(doc *html.Node) string {
+	return findText(doc, c.Author)
+}
+
+// FindImage returns the absolute link to the image matched by the image rules
+func (c *Config) FindImage(doc *html.Node, base *url.URL) string {
+	return findLink(doc, base, c.Image)
+}
+
+func findText(doc *html.Node, selectors []Selector) string {
+	for _, sel := range selectors {
+		for _, n := range sel.Find(doc) {
+			if text := strings.TrimSpace(htmlquery.InnerText(n)); text != "" {
+				return strings.Join(strings.Fields(text), " ")
+			}
+		}
+	}
+	return ""
+}
+
+// findLink returns the href, src or content attribute of the first matched element,
+// or the value of a matched attribute node (//a[@rel='next']/@href)
+func findLink(doc *html.Node, base *url.URL, selectors []Selector) string {
+	for _, sel := range selectors {
+		for _, n := range sel.Find(doc) {
+			link := ""
+			for _, key := range []string{"href", "src", "content"} {
+				if link = htmlquery.SelectAttr(n, key); link != "" {
+					break
+				}
+			}
+			if link == "" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				link = n.FirstChild.Data
+			}
+			link = strings.TrimSpace(link)
+			if link == "" {
+				continue
+			}
+			u, err := base.Parse(link)
+			if err != nil {
+				continue
+			}
+			return u.String()
+		}
+	}
+	return ""
+}
+
+// Registry holds the site configs registered from lua
+// and the ones loaded from the rule files in the rules directory
+type Registry struct {
+	dir     string
+	mu      sync.Mutex
+	configs map[string]*Config
+	// misses are the times the rule files weren't found, they are looked up again
+	// after the missTTL, so a rule file added while photon runs is used
+	misses map[string]time.Time
+}
+
+// missTTL is how long a missing rule file isn't looked up again
+const missTTL = time.Minute
+
+// NewRegistry creates a registry that loads the rule files from dir,
+// the rule files are named by the host: example.com.txt,
+// or by the parent domain for all the subdomains: .example.com.txt
+func NewRegistry(dir string) *Registry {
+	return &Registry{
+		dir:     dir,
+		configs: make(map[string]*Config),
+		misses:  make(map[string]time.Time),
+	}
+}
+
+// Add registers the config for the host, it takes precedence over the rule files
+func (r *Registry) Add(host string, c *Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[host] = c
+}
+
+// Lookup returns the config for the host, or nil if there isn't one
+func (r *Registry) Lookup(host string) *Config {
+	if r == nil || host == "" {
+		return nil
+	}
+	host = strings.ToLower(host)
+	candidates := []string{host}
+	if h := strings.TrimPrefix(host, "www."); h != host {
+		candidates = append(candidates, h)
+	}
+	for domain := host; strings.Contains(domain, "."); {
+		candidates = append(candidates, "."+domain)
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range candidates {
+		if c := r.configs[name]; c != nil {
+			return c
+		}
+		if t, ok := r.misses[name]; ok && time.Since(t) < missTTL {
+			continue
+		}
+		c := r.load(name)
+		if c == nil {
+			r.misses[name] = time.Now()
+			continue
+		}
+		delete(r.misses, name)
+		r.configs[name] = c
+		return c
+	}
+	return nil
+}
+
+func (r *Registry) load(name string) *Config {
+	if r.dir == "" {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(r.dir, name+".txt"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		log.Printf("ERROR: parsing site config %s: %s", name, err)
+		return nil
+	}
+	return c
+}
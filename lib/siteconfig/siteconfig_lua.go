@@ -0,0 +1,29 @@
+package siteconfig
+
+import (
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func NewLValue(L *lua.LState, r *Registry) lua.LValue {
+	exports := map[string]lua.LGFunction{
+		"add": siteConfigAdd(r),
+	}
+	return L.SetFuncs(L.NewTable(), exports)
+}
+
+// siteConfigAdd registers the rules for the host,
+// the rules are a string in the rule file format
+func siteConfigAdd(r *Registry) lua.LGFunction {
+	return func(L *lua.LState) int {
+		host := strings.ToLower(L.CheckString(1))
+		c, err := Parse(strings.NewReader(L.CheckString(2)))
+		if err != nil {
+			L.ArgError(2, err.Error())
+			return 0
+		}
+		r.Add(host, c)
+		return 0
+	}
+}
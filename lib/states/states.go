@@ -6,6 +6,14 @@ const (
 	Normal Enum = iota
 	Article
 	Search
+	ArticleSearch
+	History
+	Formats
+	Queue
+	Downloads
+	AutoDownload
+	MediaItems
+	Processes
 )
 
 type Func func() Enum
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/cmdline"
+	"git.sr.ht/~ghost08/photon/lib/export"
+	"git.sr.ht/~ghost08/photon/lib/media"
+)
+
+// WithTerminalHandlers marks the default handlers (video, image, torrent) as terminal programs,
+// they are run in the foreground with the screen suspended
+func WithTerminalHandlers(kinds []string) Option {
+	return func(p *Photon) {
+		for _, kind := range kinds {
+			switch kind {
+			case "video":
+				p.mediaExtractor.VideoTerminal = true
+			case "image":
+				p.mediaExtractor.ImageTerminal = true
+			case "torrent":
+				p.mediaExtractor.TorrentTerminal = true
+			default:
+				log.Println("ERROR: unknown terminal handler:", kind)
+			}
+		}
+	}
+}
+
+// OpenInPager opens the card's article as markdown in the $PAGER (less if it isn't set)
+func (card *Card) OpenInPager() {
+	card.openInTerminal("PAGER", "less")
+}
+
+// OpenInEditor opens the card's article as markdown in the $EDITOR (vi if it isn't set)
+func (card *Card) OpenInEditor() {
+	card.openInTerminal("EDITOR", "vi")
+}
+
+// openInTerminal writes the card's article to a temporary markdown file and opens it
+// with the command from the environment variable in the foreground
+func (card *Card) openInTerminal(env, fallback string) {
+	if card == nil {
+		return
+	}
+	command := os.Getenv(env)
+	if command == "" {
+		command = fallback
+	}
+	card.photon.SetStatusWithSpinner(fmt.Sprintf("Loading %s", card.Item.Title))
+	go func() {
+		if err := card.runInTerminal(command); err != nil {
+			log.Printf("ERROR: opening article in $%s: %s", env, err)
+			card.photon.StatusWithTimeout(fmt.Sprintf("ERROR: opening article in $%s: %s", env, err), time.Second*3)
+		}
+	}()
+}
+
+// runInTerminal exports the card's article to a temporary markdown file and runs the command with it
+func (card *Card) runInTerminal(command string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportFetchTimeout)
+	doc, err := card.exportDocument(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "photon-*.md")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if err := export.Markdown(f, doc); err != nil {
+		f.Close()
+		return fmt.Errorf("writing article: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+	args, err := cmdline.Split(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...) //nolint:gosec // we trust the user
+	in, out := media.TTY()
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, out
+	card.photon.SetStatus("")
+	return card.photon.cb.RunInTerminal(cmd.Run)
+}
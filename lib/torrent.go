@@ -0,0 +1,266 @@
+package lib
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// the torrent metadata of the item, normalized from the nyaa and torznab extensions
+const (
+	customTorrentSeeders  = "torrentSeeders"
+	customTorrentLeechers = "torrentLeechers"
+	customTorrentSize     = "torrentSize"
+	customTorrentInfoHash = "torrentInfoHash"
+	customTorrentMagnet   = "torrentMagnet"
+	customTorrentURL      = "torrentURL"
+)
+
+// the orders of the cards
+const (
+	SortDate    = "date"
+	SortSeeders = "seeders"
+	SortSize    = "size"
+)
+
+// SortOrders are the orders the cards can be sorted by
+var SortOrders = []string{SortDate, SortSeeders, SortSize}
+
+// Torrent is the torrent metadata of a item from a torrent feed (nyaa, torznab)
+type Torrent struct {
+	// Seeders and Leechers are -1 if they aren't known
+	Seeders  int
+	Leechers int
+	// Size is in bytes, 0 if it isn't known
+	Size     int64
+	InfoHash string
+	Magnet   string
+	// URL is the link to the .torrent file
+	URL string
+}
+
+// WithTorrentLink sets the link of the torrent items handed to the torrent command,
+// magnet or torrent (the .torrent file), the other one is used if the item doesn't have it
+func WithTorrentLink(preference string) Option {
+	return func(p *Photon) {
+		p.torrentLink = preference
+	}
+}
+
+// WithSort sets the order of the cards (date, seeders, size)
+func WithSort(by string) Option {
+	return func(p *Photon) {
+		p.sortBy = by
+	}
+}
+
+// SortBy returns the order of the cards
+func (p *Photon) SortBy() string {
+	if p.sortBy == "" {
+		return SortDate
+	}
+	return p.sortBy
+}
+
+// SetSortBy sorts the cards by the order (date, seeders, size)
+func (p *Photon) SetSortBy(by string) {
+	p.sortBy = by
+	p.sortCards()
+	p.filterCards()
+	p.cb.Redraw()
+}
+
+// sortCards sorts the cards by the date, or by the seeders or size of the torrents,
+// cards with the same value (or without the torrent metadata) stay sorted by the date
+func (p *Photon) sortCards() {
+	sort.Sort(p.Cards)
+	var value func(t Torrent) int64
+	switch p.sortBy {
+	case SortSeeders:
+		value = func(t Torrent) int64 { return int64(t.Seeders) }
+	case SortSize:
+		value = func(t Torrent) int64 { return t.Size }
+	default:
+		return
+	}
+	sort.SliceStable(p.Cards, func(i, j int) bool {
+		ti, iok := p.Cards[i].Torrent()
+		tj, jok := p.Cards[j].Torrent()
+		if !iok || !jok {
+			return iok && !jok
+		}
+		return value(ti) > value(tj)
+	})
+}
+
+// Torrent returns the torrent metadata of the card, false if it isn't a torrent
+func (card *Card) Torrent() (Torrent, bool) {
+	c := card.Item.Custom
+	t := Torrent{
+		Seeders:  -1,
+		Leechers: -1,
+		InfoHash: c[customTorrentInfoHash],
+		Magnet:   c[customTorrentMagnet],
+		URL:      c[customTorrentURL],
+	}
+	if t.InfoHash == "" && t.Magnet == "" && t.URL == "" {
+		return Torrent{}, false
+	}
+	if n, err := strconv.Atoi(c[customTorrentSeeders]); err == nil {
+		t.Seeders = n
+	}
+	if n, err := strconv.Atoi(c[customTorrentLeechers]); err == nil {
+		t.Leechers = n
+	}
+	t.Size, _ = strconv.ParseInt(c[customTorrentSize], 10, 64)
+	return t, true
+}
+
+// link returns the link of the torrent handed to the torrent command, by the preference
+// (magnet or torrent), and it's content type
+func (t Torrent) link(preference string) (link, contentType string) {
+	switch {
+	case t.URL != "" && (preference == "torrent" || t.Magnet == ""):
+		return t.URL, "application/x-bittorrent"
+	default:
+		return t.Magnet, "magnet-link"
+	}
+}
+
+// torrentFile returns the link of the card's .torrent file, the torrents are downloaded
+// as the .torrent file, the magnet links can't be downloaded
+func (card *Card) torrentFile() (string, bool) {
+	t, ok := card.Torrent()
+	return t.URL, ok && t.URL != ""
+}
+
+// torrentMeta reads the torrent metadata from the nyaa and torznab extensions, the enclosures
+// and the magnet links of the item, to the item's custom fields
+func torrentMeta(i *gofeed.Item) {
+	set := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" && i.Custom[key] == "" {
+			i.Custom[key] = value
+		}
+	}
+	// nyaa.si
+	if nyaa, ok := i.Extensions["nyaa"]; ok {
+		value := func(name string) string {
+			v, _ := getExt(func() string { return nyaa[name][0].Value })
+			return v
+		}
+		set(customTorrentSeeders, value("seeders"))
+		set(customTorrentLeechers, value("leechers"))
+		set(customTorrentInfoHash, value("infoHash"))
+		if size, ok := parseSize(value("size")); ok {
+			set(customTorrentSize, strconv.FormatInt(size, 10))
+		}
+	}
+	// torznab (jackett, prowlarr)
+	for _, ns := range []string{"torznab", "newznab"} {
+		for _, attr := range i.Extensions[ns]["attr"] {
+			value := attr.Attrs["value"]
+			switch strings.ToLower(attr.Attrs["name"]) {
+			case "seeders":
+				set(customTorrentSeeders, value)
+			case "leechers":
+				set(customTorrentLeechers, value)
+			case "peers":
+				// peers are the seeders and the leechers
+				seeders, err1 := strconv.Atoi(i.Custom[customTorrentSeeders])
+				peers, err2 := strconv.Atoi(value)
+				if err1 == nil && err2 == nil && i.Custom[customTorrentLeechers] == "" {
+					set(customTorrentLeechers, strconv.Itoa(max(0, peers-seeders)))
+				}
+			case "size":
+				set(customTorrentSize, value)
+			case "infohash":
+				set(customTorrentInfoHash, value)
+			case "magneturl":
+				set(customTorrentMagnet, value)
+			}
+		}
+	}
+	for _, e := range i.Enclosures {
+		switch {
+		case strings.HasPrefix(e.URL, "magnet:"):
+			set(customTorrentMagnet, e.URL)
+		case e.Type == "application/x-bittorrent" || isTorrentLink(e.URL):
+			set(customTorrentURL, e.URL)
+			if e.Length != "0" {
+				set(customTorrentSize, e.Length)
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(i.Link, "magnet:"):
+		set(customTorrentMagnet, i.Link)
+	case isTorrentLink(i.Link):
+		set(customTorrentURL, i.Link)
+	}
+	if hash := i.Custom[customTorrentInfoHash]; hash != "" && i.Custom[customTorrentMagnet] == "" {
+		set(customTorrentMagnet, "magnet:?xt=urn:btih:"+hash+"&dn="+url.QueryEscape(i.Title))
+	}
+	if i.Custom[customTorrentInfoHash] == "" {
+		set(customTorrentInfoHash, magnetInfoHash(i.Custom[customTorrentMagnet]))
+	}
+}
+
+// isTorrentLink reports if the link is to a .torrent file
+func isTorrentLink(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Path), ".torrent")
+}
+
+// magnetInfoHash returns the info hash of the magnet link (the urn:btih: of xt)
+func magnetInfoHash(magnet string) string {
+	u, err := url.Parse(magnet)
+	if err != nil || u.Scheme != "magnet" {
+		return ""
+	}
+	for _, xt := range u.Query()["xt"] {
+		if hash, ok := strings.CutPrefix(xt, "urn:btih:"); ok {
+			return hash
+		}
+	}
+	return ""
+}
+
+// sizeUnits are the multipliers of the size units, nyaa uses the binary units (GiB)
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// parseSize parses the size with the unit (1.4 GiB, 700 MB) or in bytes to bytes
+func parseSize(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	n := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if n == -1 {
+		n = len(s)
+	}
+	number, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, false
+	}
+	unit := strings.ToLower(strings.TrimSpace(s[n:]))
+	if unit == "" {
+		unit = "b"
+	}
+	m, ok := sizeUnits[unit]
+	if !ok {
+		return 0, false
+	}
+	return int64(number * m), true
+}
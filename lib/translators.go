@@ -70,7 +70,7 @@ loopDomTest:
 func findImage(item *gofeed.Item) {
 	for _, e := range item.Enclosures {
 		if strings.HasPrefix(e.Type, "image/") {
-			item.Image = &gofeed.Image{URL: item.Enclosures[0].URL}
+			item.Image = &gofeed.Image{URL: e.URL}
 			return
 		}
 	}
@@ -92,6 +92,39 @@ func findImage(item *gofeed.Item) {
 	}
 }
 
+// atomComments sets the comment feed and count of the item,
+// from the replies link (RFC 4685) and thr:total
+func atomComments(i *gofeed.Item, entry *atom.Entry) {
+	for _, l := range entry.Links {
+		if l.Rel != "replies" {
+			continue
+		}
+		switch l.Type {
+		case "", "application/atom+xml", "application/rss+xml":
+			i.Custom[customCommentsFeed] = l.Href
+		case "text/html":
+			i.Custom[customCommentsLink] = l.Href
+		}
+	}
+	if total, ok := getExt(func() string { return i.Extensions["thr"]["total"][0].Value }); ok {
+		i.Custom[customCommentsCount] = strings.TrimSpace(total)
+	}
+}
+
+// rssComments sets the comment feed, page and count of the item,
+// from wfw:commentRss, <comments> and slash:comments
+func rssComments(i *gofeed.Item, item *rss.Item) {
+	if item.Comments != "" {
+		i.Custom[customCommentsLink] = item.Comments
+	}
+	if feed, ok := getExt(func() string { return i.Extensions["wfw"]["commentRss"][0].Value }); ok {
+		i.Custom[customCommentsFeed] = strings.TrimSpace(feed)
+	}
+	if count, ok := getExt(func() string { return i.Extensions["slash"]["comments"][0].Value }); ok {
+		i.Custom[customCommentsCount] = strings.TrimSpace(count)
+	}
+}
+
 type customAtomTranslator struct {
 	defaultTranslator *gofeed.DefaultAtomTranslator
 }
@@ -117,7 +150,7 @@ func (ct *customAtomTranslator) Translate(feed any) (*gofeed.Feed, error) {
 		f.Image = &gofeed.Image{URL: strings.TrimSuffix(atom.Icon, "/")}
 	}
 
-	for _, i := range f.Items {
+	for n, i := range f.Items {
 		if i.Image == nil || i.Image.URL == "" {
 			findImage(i)
 		}
@@ -125,6 +158,9 @@ func (ct *customAtomTranslator) Translate(feed any) (*gofeed.Feed, error) {
 			i.Description = val
 		}
 		scrapContent(i)
+		if n < len(atom.Entries) {
+			atomComments(i, atom.Entries[n])
+		}
 		if i.PublishedParsed == nil {
 			if i.UpdatedParsed == nil {
 				pubdate, ok := i.Custom["pubdate"]
@@ -166,11 +202,20 @@ func (ct *customRSSTranslator) Translate(feed any) (*gofeed.Feed, error) {
 		return nil, err
 	}
 
-	for _, i := range f.Items {
+	podcastImage := podcastArtwork(rss, f)
+	for n, i := range f.Items {
 		if i.Image == nil || i.Image.URL == "" {
 			findImage(i)
 		}
+		// podcast episodes without artwork show the artwork of the podcast
+		if (i.Image == nil || i.Image.URL == "") && podcastImage != "" && isEpisode(i) {
+			i.Image = &gofeed.Image{URL: podcastImage}
+		}
 		scrapContent(i)
+		torrentMeta(i)
+		if n < len(rss.Items) {
+			rssComments(i, rss.Items[n])
+		}
 		if i.PublishedParsed == nil {
 			if i.UpdatedParsed == nil {
 				pubdate, ok := i.Custom["pubdate"]
@@ -190,3 +235,27 @@ func (ct *customRSSTranslator) Translate(feed any) (*gofeed.Feed, error) {
 	}
 	return f, nil
 }
+
+// podcastArtwork returns the itunes:image of the podcast, or the feed image
+func podcastArtwork(rss *rss.Feed, f *gofeed.Feed) string {
+	if rss.ITunesExt != nil && rss.ITunesExt.Image != "" {
+		return rss.ITunesExt.Image
+	}
+	if f.Image != nil {
+		return f.Image.URL
+	}
+	return ""
+}
+
+// isEpisode reports if the item is a podcast episode, it has the itunes extension or a audio enclosure
+func isEpisode(item *gofeed.Item) bool {
+	if item.ITunesExt != nil {
+		return true
+	}
+	for _, e := range item.Enclosures {
+		if strings.HasPrefix(e.Type, "audio/") {
+			return true
+		}
+	}
+	return false
+}
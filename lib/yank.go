@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// the texts of the card copied by the yank actions
+const (
+	YankLink     = "link"
+	YankTitle    = "title"
+	YankMarkdown = "markdown"
+	YankMedia    = "media"
+	YankImage    = "image"
+	YankArticle  = "article"
+)
+
+// Yank copies the text of the card (link, title, markdown, media, image, article) with the copy function,
+// the media link and the article text are loaded in the background
+func (card *Card) Yank(what string, copyText func(string) error) {
+	if card == nil {
+		return
+	}
+	yank := func() {
+		text, err := card.yankText(what)
+		if err == nil {
+			err = copyText(text)
+		}
+		if err != nil {
+			log.Printf("ERROR: copying %s: %s", what, err)
+			card.photon.StatusWithTimeout(fmt.Sprintf("ERROR: copying %s: %s", what, err), time.Second*3)
+			return
+		}
+		card.photon.StatusWithTimeout("Copied "+what, time.Second*2)
+	}
+	if what != YankMedia && what != YankArticle {
+		yank()
+		return
+	}
+	card.photon.SetStatusWithSpinner(fmt.Sprintf("Loading %s of %s", what, card.Item.Title))
+	go yank()
+}
+
+// yankText returns the text of the card copied by the yank action
+func (card *Card) yankText(what string) (string, error) {
+	switch what {
+	case YankLink:
+		return card.Item.Link, nil
+	case YankTitle:
+		return card.Item.Title, nil
+	case YankMarkdown:
+		title := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(card.Item.Title)
+		return "[" + title + "](" + card.Item.Link + ")", nil
+	case YankMedia:
+		m, err := card.GetMedia()
+		if err != nil {
+			return "", fmt.Errorf("extracting media link: %w", err)
+		}
+		if len(m.Links) == 0 {
+			return "", errors.New("item has no media")
+		}
+		return strings.Join(m.Links, "\n"), nil
+	case YankImage:
+		if card.Item.Image != nil && card.Item.Image.URL != "" {
+			return card.Item.Image.URL, nil
+		}
+		if article := card.loadedArticle(); article != nil && article.Image != "" {
+			return article.Image, nil
+		}
+		return "", errors.New("item has no image")
+	case YankArticle:
+		ctx, cancel := context.WithTimeout(context.Background(), exportFetchTimeout)
+		defer cancel()
+		article, err := card.loadArticle(ctx)
+		if err != nil {
+			return "", fmt.Errorf("loading article: %w", err)
+		}
+		return strings.TrimSpace(article.TextContent), nil
+	}
+	return "", fmt.Errorf("unknown yank `%s`", what)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+)
+
+var openedList *List
+
+// List is a full screen view of selectable rows (history, ...),
+// every list has it's own state, so it can have it's own keybindings
+type List struct {
+	Title string
+	State states.Enum
+	// Rows returns the rows of the list, it's called on every draw,
+	// so the list shows the current content
+	Rows func() []Richtext
+
+	selected int
+	offset   int
+	height   int
+	length   int
+}
+
+var listSelectedStyle = tcell.StyleDefault.Background(selectedColor)
+
+func openList(l *List) {
+	openedList = l
+	redraw(true)
+}
+
+func closeList(s tcell.Screen) {
+	openedList = nil
+	s.Clear()
+	redraw(true)
+}
+
+func (l *List) Draw(ctx Context, s tcell.Screen) Richtext {
+	s.Clear()
+	rows := l.Rows()
+	l.length = len(rows)
+	l.height = max(1, ctx.Height-2)
+	l.selected = max(0, min(l.selected, len(rows)-1))
+	switch {
+	case l.selected < l.offset:
+		l.offset = l.selected
+	case l.selected >= l.offset+l.height:
+		l.offset = l.selected - l.height + 1
+	}
+	drawLine(s, 1, 0, ctx.Width-2, l.Title, tcell.StyleDefault.Foreground(tcell.ColorWhiteSmoke).Bold(true))
+	for i := l.offset; i < len(rows) && i < l.offset+l.height; i++ {
+		y := i - l.offset + 2
+		row := rows[i]
+		if i == l.selected {
+			for x := 0; x < ctx.Width; x++ {
+				s.SetContent(x, y, ' ', nil, listSelectedStyle)
+			}
+			row = maprt(row, func(to textobject) textobject {
+				to.Style = to.Style.Background(selectedColor)
+				return to
+			})
+		}
+		drawRichtext(s, 1, y, ctx.Width-2, 0, row)
+	}
+	if len(rows) == 0 {
+		return Richtext{{Text: "empty", Style: tcell.StyleDefault}}
+	}
+	return Richtext{{Text: fmt.Sprintf("%d/%d", l.selected+1, len(rows)), Style: tcell.StyleDefault}}
+}
+
+// Move moves the selection by d rows
+func (l *List) Move(d int) {
+	l.selected = max(0, min(l.selected+d, l.length-1))
+	redraw(false)
+}
+
+// Selected returns the index of the selected row
+func (l *List) Selected() int {
+	return l.selected
+}
+
+// addListKeyBindings registers the movement and closing keys for the list state
+func addListKeyBindings(state states.Enum, s tcell.Screen) {
+	move := func(d func(*List) int) func() error {
+		return func() error {
+			if openedList != nil {
+				openedList.Move(d(openedList))
+			}
+			return nil
+		}
+	}
+	photon.KeyBindings.Add(state, "j", move(func(*List) int { return 1 }))
+	photon.KeyBindings.Add(state, "k", move(func(*List) int { return -1 }))
+	photon.KeyBindings.Add(state, "<ctrl>d", move(func(l *List) int { return l.height / 2 }))
+	photon.KeyBindings.Add(state, "<ctrl>u", move(func(l *List) int { return -l.height / 2 }))
+	photon.KeyBindings.Add(state, "<ctrl>f", move(func(l *List) int { return l.height }))
+	photon.KeyBindings.Add(state, "<ctrl>b", move(func(l *List) int { return -l.height }))
+	photon.KeyBindings.Add(state, "gg", move(func(l *List) int { return -l.length }))
+	photon.KeyBindings.Add(state, "<shift>g", move(func(l *List) int { return l.length }))
+	closeFn := func() error {
+		closeList(s)
+		return nil
+	}
+	photon.KeyBindings.Add(state, "<esc>", closeFn)
+	photon.KeyBindings.Add(state, "q", closeFn)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime/pprof"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -23,21 +24,44 @@ import (
 	"github.com/alecthomas/kong"
 	"github.com/gdamore/tcell/v2"
 	"github.com/mattn/go-isatty"
+	"github.com/mattn/go-runewidth"
 )
 
 var CLI struct {
-	Extractor       string       `optional:"" default:"yt-dlp --get-url %" help:"command for media link extraction (item link is substituted for %)" env:"PHOTON_EXTRACTOR"`
-	VideoCmd        string       `optional:"" default:"mpv $" help:"set default command for opening the item media link in a video player (media link is substituted for %, direct item link is substituted for $, if no % or $ is provided, photon will download the data and pipe it to the stdin of the command)" env:"PHOTON_VIDEOCMD"`
-	ImageCmd        string       `optional:"" default:"imv -" help:"set default command for opening the item media link in a image viewer (media link is substituted for %, direct item link is substituted for $, if no % or $ is provided, photon will download the data and pipe it to the stdin of the command)" env:"PHOTON_IMAGECMD"`
-	TorrentCmd      string       `optional:"" default:"mpv %" help:"set default command for opening the item media link in a torrent downloader (media link is substituted for %, if link is a torrent file, photon will download it, and substitute the torrent file path for %)" env:"PHOTON_TORRENTCMD"`
-	ArticleMode     string       `optional:"" default:"ARTICLE" enum:"ARTICLE,DESCRIPTION,CONTENT" help:"the default article view mode" env:"PHOTON_ARTICLE_MODE"`
-	ArticleRenderer string       `optional:"" default:"w3m -T text/html -dump -cols 72" help:"command to render the item.Content/item.Description" env:"PHOTON_ARTICLE_RENDERER"`
-	HTTPSettings    HTTPSettings `embed:""`
-	DownloadPath    string       `optional:"" default:"$HOME/Downloads" help:"the default download path"`
-	TerminalTitle   string       `short:"t" optional:"" help:"set the terminal title"`
-	Refresh         uint         `short:"r" optional:"" default:"0" help:"set refresh interval in seconds" env:"PHOTON_REFRESH"`
-	Pprof           bool         `optional:"" default:"false" help:"will create a cpu.pprof profiling file"`
-	Paths           []string     `arg:"" optional:"" help:"RSS/Atom urls, config path, or - for stdin"`
+	Extractor          string       `optional:"" default:"yt-dlp --get-url %" help:"command for media link extraction (item link is substituted for {url} or %)" env:"PHOTON_EXTRACTOR"`
+	ExtractorMode      string       `optional:"" default:"urls" enum:"urls,json" help:"urls runs the --extractor, which prints the media links, json runs the --json-extractor, which prints the media info with the formats, duration and chapters (urls, json)" env:"PHOTON_EXTRACTOR_MODE"`
+	JSONExtractor      string       `optional:"" default:"yt-dlp -J --no-warnings {url}" help:"command printing the media info as json, used in the json extractor mode (item link is substituted for {url})" env:"PHOTON_JSON_EXTRACTOR"`
+	QueuePlayer        string       `optional:"" default:"mpv --force-window=yes" help:"mpv command playing the queue, photon controls it through the mpv IPC socket" env:"PHOTON_QUEUE_PLAYER"`
+	VideoCmd           string       `optional:"" default:"mpv --save-position-on-quit --ytdl-format={format} {url}" help:"set default command for opening the item media link in a video player (media link is substituted for {media} or %, direct item link is substituted for {url} or $, the format picked in the format picker for {format}, the saved playback position in seconds for {start}, if no % or $ is provided, photon will download the data and pipe it to the stdin of the command)" env:"PHOTON_VIDEOCMD"`
+	ImageCmd           string       `optional:"" default:"imv -" help:"set default command for opening the item media link in a image viewer (media link is substituted for {media} or %, direct item link is substituted for {url} or $, the item title for {title}, the feed title for {feed}, a temporary file with the downloaded media for {file}, if no {media}, {url}, {file}, % or $ is provided, photon will download the data and pipe it to the stdin of the command)" env:"PHOTON_IMAGECMD"`
+	TorrentLink        string       `optional:"" default:"magnet" enum:"magnet,torrent" help:"the link of the torrent feed items handed to the --torrent-cmd, the magnet link or the .torrent file (magnet, torrent)" env:"PHOTON_TORRENT_LINK"`
+	TorrentCmd         string       `optional:"" default:"mpv %" help:"set default command for opening the item media link in a torrent downloader (the magnet or torrent link is substituted for {media}, the item link for {url}, if link is a torrent file, photon will download it, and substitute the torrent file path for {file} or %)" env:"PHOTON_TORRENTCMD"`
+	MediaHandler       []string     `optional:"" sep:"none" help:"add a media handler PATTERN[:PRIORITY][:terminal]=COMMAND, media with a content-type matching the PATTERN (with * wildcards) is opened with the COMMAND, the --video-cmd, --image-cmd and --torrent-cmd are the default handlers with priority 0, with :terminal the COMMAND runs in the foreground with the screen suspended (can be repeated)" env:"PHOTON_MEDIA_HANDLER"`
+	TerminalHandler    []string     `optional:"" enum:"video,image,torrent" help:"run the --video-cmd, --image-cmd or --torrent-cmd in the terminal, photon suspends the screen while it runs (video, image, torrent, can be repeated)" env:"PHOTON_TERMINAL_HANDLER"`
+	LinkHandler        []string     `optional:"" sep:"none" help:"add a link handler MATCH=ACTION for opening the item links with o and enter, the MATCH is a host (with it's subdomains) or a ~regexp of the link, the ACTION is browser, article, media or a command, the first matching handler is used (can be repeated)" env:"PHOTON_LINK_HANDLER"`
+	ArticleMode        string       `optional:"" default:"ARTICLE" help:"the default article view mode (ARTICLE, DESCRIPTION, CONTENT, COMMENTS, SOURCE, MEDIA or a added mode)" env:"PHOTON_ARTICLE_MODE"`
+	ArticleRenderer    string       `optional:"" default:"" help:"command to render the item.Content/item.Description (if empty, the built-in html renderer is used)" env:"PHOTON_ARTICLE_RENDERER"`
+	ArticleCmdMode     []string     `optional:"" sep:"none" help:"add a article view mode NAME=COMMAND, the article text is piped to the command and it's output is shown (can be repeated)" env:"PHOTON_ARTICLE_CMD_MODE"`
+	CodeStyle          string       `optional:"" default:"monokai" help:"syntax highlighting style of the code blocks in the article view" env:"PHOTON_CODE_STYLE"`
+	ArticleMaxPages    int          `optional:"" default:"5" help:"maximum number of pages fetched for articles split over several pages (1 fetches only the first page, except for the next_page_link site config rules)" env:"PHOTON_ARTICLE_MAX_PAGES"`
+	HTTPSettings       HTTPSettings `embed:""`
+	DownloadPath       string       `optional:"" default:"$HOME/Downloads" help:"the default download path"`
+	DownloadWorkers    int          `optional:"" default:"3" help:"number of links downloaded at the same time" env:"PHOTON_DOWNLOAD_WORKERS"`
+	DownloadName       string       `optional:"" default:"{title}.{ext}" help:"template of the downloaded file names, with the {feed}, {title}, {author}, {date:LAYOUT} and {ext} placeholders, slashes create directories" env:"PHOTON_DOWNLOAD_NAME"`
+	DownloadSidecar    []string     `optional:"" enum:"json,nfo" help:"write the item metadata to a json or nfo file next to the downloaded file (json, nfo, can be repeated)" env:"PHOTON_DOWNLOAD_SIDECAR"`
+	AutoDownload       bool         `optional:"" default:"false" help:"after downloading the feeds, download the new items matching the auto-download rules" env:"PHOTON_AUTO_DOWNLOAD"`
+	AutoDownloadRules  string       `optional:"" help:"path to the auto-download rules file (default is ~/.config/photon/autodownload)" env:"PHOTON_AUTO_DOWNLOAD_RULES"`
+	AutoDownloadDryRun bool         `optional:"" default:"false" help:"only list the items matching the auto-download rules in the auto-download view, without downloading them" env:"PHOTON_AUTO_DOWNLOAD_DRY_RUN"`
+	OfflineSync        bool         `optional:"" default:"false" help:"after downloading the feeds, fetch the articles and top images of new items in the background for offline reading" env:"PHOTON_OFFLINE_SYNC"`
+	OfflineFilter      string       `optional:"" help:"fetch only the articles of items matching this search query" env:"PHOTON_OFFLINE_FILTER"`
+	OfflineWorkers     int          `optional:"" default:"4" help:"number of articles fetched at the same time by the offline sync" env:"PHOTON_OFFLINE_WORKERS"`
+	Sort               string       `optional:"" default:"date" enum:"date,seeders,size" help:"order of the cards, by the publish date, or by the seeders or size of the torrents (date, seeders, size)" env:"PHOTON_SORT"`
+	Clipboard          string       `optional:"" default:"osc52" help:"the clipboard backend of the copy actions, osc52, wl-copy, xclip or a command, the text is piped to it's stdin" env:"PHOTON_CLIPBOARD"`
+	OnExit             string       `optional:"" default:"kill" enum:"kill,keep" help:"what happens with the running players and commands when photon exits, kill them or keep them running (kill, keep)" env:"PHOTON_ON_EXIT"`
+	TerminalTitle      string       `short:"t" optional:"" help:"set the terminal title"`
+	Refresh            uint         `short:"r" optional:"" default:"0" help:"set refresh interval in seconds" env:"PHOTON_REFRESH"`
+	Pprof              bool         `optional:"" default:"false" help:"will create a cpu.pprof profiling file"`
+	Paths              []string     `arg:"" optional:"" help:"RSS/Atom urls, config path, or - for stdin"`
 }
 
 var (
@@ -48,6 +72,8 @@ var (
 	command         string
 	commandFocus    bool
 	redrawCh        = make(chan bool, 1024)
+	// screen is suspended, when a terminal program runs in the foreground
+	screen tcell.Screen
 )
 
 func main() {
@@ -80,11 +106,13 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	// the terminal programs (handlers, $PAGER, $EDITOR) run on the tty, see media.TTY
+	stdout := os.Stdout
 	isTerminal := isatty.IsTerminal(os.Stdout.Fd())
 	if isTerminal {
 		// don't log to terminal
 		log.SetOutput(io.Discard)
-		os.Stdout, _ = os.Open(os.DevNull)
+		os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
 	} else {
 		// log to redirected stdout
 		log.SetOutput(os.Stdout)
@@ -92,7 +120,7 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 	if CLI.TerminalTitle != "" {
-		setTerminalTitle(CLI.TerminalTitle)
+		setTerminalTitle(stdout, CLI.TerminalTitle)
 	}
 
 	if len(CLI.Paths) == 0 {
@@ -117,7 +145,27 @@ func main() {
 		lib.WithMediaVideoCmd(CLI.VideoCmd),
 		lib.WithMediaImageCmd(CLI.ImageCmd),
 		lib.WithMediaTorrentCmd(CLI.TorrentCmd),
+		lib.WithTorrentLink(CLI.TorrentLink),
+		lib.WithSort(CLI.Sort),
+		lib.WithOnExit(CLI.OnExit),
+		lib.WithMediaHandlers(CLI.MediaHandler),
+		lib.WithTerminalHandlers(CLI.TerminalHandler),
+		lib.WithLinkHandlers(CLI.LinkHandler),
+		lib.WithQueuePlayer(CLI.QueuePlayer),
 		lib.WithDownloadPath(CLI.DownloadPath),
+		lib.WithDownloadWorkers(CLI.DownloadWorkers),
+		lib.WithDownloadName(CLI.DownloadName),
+		lib.WithDownloadSidecars(CLI.DownloadSidecar),
+		lib.WithArticleMaxPages(CLI.ArticleMaxPages),
+	}
+	if CLI.ExtractorMode == "json" {
+		options = append(options, lib.WithMediaJSONExtractor(CLI.JSONExtractor))
+	}
+	if CLI.AutoDownload || CLI.AutoDownloadDryRun {
+		options = append(options, lib.WithAutoDownload(CLI.AutoDownloadRules, CLI.AutoDownloadDryRun))
+	}
+	if CLI.OfflineSync {
+		options = append(options, lib.WithOfflineSync(CLI.OfflineFilter, CLI.OfflineWorkers))
 	}
 	if err := imgproc.Init(!isTerminal); err != nil {
 		log.Printf("INFO: error loading opencl image resizer, falling back to CPU scaling: %s", err)
@@ -130,6 +178,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer photon.Close()
+	parseCmdArticleModes(CLI.ArticleCmdMode)
+	if defaultArticleMode, err = articleModeFromString(CLI.ArticleMode); err != nil {
+		photon.Close()
+		log.Fatal(err)
+	}
 
 	// tui
 	tcell.SetEncodingFallback(tcell.EncodingFallbackASCII)
@@ -142,6 +196,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+	screen = s
 	defer s.Fini()
 
 	grid.Resize(ctx)
@@ -176,6 +231,9 @@ func main() {
 					grid.ClearCardsPosition()
 					continue
 				}
+				if articleSearchInput(s, ev) {
+					continue
+				}
 				photon.KeyBindings.Run(newKeyEvent(ev))
 			case *tcell.EventResize:
 				s.Clear()
@@ -189,7 +247,7 @@ func main() {
 					} else {
 						grid.ClearCardsPosition()
 					}
-				case states.Article:
+				case states.Article, states.ArticleSearch:
 					openedArticle.Clear()
 				}
 				ctx, quit = WithCancel(newCtx)
@@ -203,6 +261,17 @@ func main() {
 	var fullRedraw bool
 	sixelScreen := &imgproc.SixelScreen{}
 	for {
+		// the screen isn't drawn while a terminal program runs
+		drawMu.Lock()
+		if terminalSuspended.Load() {
+			drawMu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case fullRedraw = <-redrawCh:
+			}
+			continue
+		}
 		// Begin synchronized update (BSU) ESC P = 1 s ESC \
 		os.Stderr.WriteString("\033P=1s\033\\")
 		// draw main widget + status bar
@@ -211,8 +280,13 @@ func main() {
 		case states.Normal, states.Search:
 			widgetStatus = grid.Draw(ctx, s, sixelScreen, fullRedraw)
 			drawCommand(ctx, s)
-		case states.Article:
+		case states.Article, states.ArticleSearch:
 			widgetStatus = openedArticle.Draw(ctx, s, sixelScreen)
+			if openedArticle.searchFocus || openedArticle.search != "" {
+				drawCommandLine(ctx, s, "/"+openedArticle.search)
+			}
+		case states.History, states.Formats, states.Queue, states.Downloads, states.AutoDownload, states.MediaItems, states.Processes:
+			widgetStatus = openedList.Draw(ctx, s)
 		}
 		status := photon.GetStatus()
 		if utf8.RuneCountInString(status) > (ctx.Width / 2) {
@@ -222,13 +296,16 @@ func main() {
 			s,
 			append(
 				Richtext{{Text: status, Style: tcell.StyleDefault}},
-				widgetStatus...,
+				append(queueStatus(), widgetStatus...)...,
 			),
 		)
 		// command line cursor
 		if commandFocus {
 			s.SetContent(len(command), int(ctx.Rows-1), ' ', nil, tcell.StyleDefault.Reverse(true))
 		}
+		if openedArticle != nil && openedArticle.searchFocus {
+			s.SetContent(runewidth.StringWidth(openedArticle.search)+1, int(ctx.Rows-1), ' ', nil, tcell.StyleDefault.Reverse(true))
+		}
 		if fullRedraw {
 			s.Sync()
 		} else {
@@ -239,6 +316,7 @@ func main() {
 		sixelScreen.Reset()
 		// end synchronized update (ESU) ESC P = 2 s ESC \
 		os.Stderr.WriteString("\033P=2s\033\\")
+		drawMu.Unlock()
 		// wait for another redraw event or quit
 		select {
 		case <-ctx.Done():
@@ -250,11 +328,10 @@ func main() {
 
 func drawStatusBar(s tcell.Screen, t Richtext) {
 	w, h := s.Size()
-	X := w - t.Len()
+	X := w - t.Width()
 	Y := h - 1
 	for _, to := range t {
-		drawString(s, X, Y, to.Text, to.Style)
-		X += len(to.Text)
+		X += drawString(s, X, Y, to.Text, to.Style)
 	}
 }
 
@@ -322,12 +399,9 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		return nil
 	})
 	photon.KeyBindings.Add(states.Normal, "<enter>", func() error {
-		SelectedCard.OpenArticle(context.Background())
-		if openedArticle != nil {
-			openedArticle.Mode = articleModeFromString(CLI.ArticleMode)
-		}
+		err := SelectedCard.OpenLink(lib.LinkArticle)
 		grid.ClearCardsPosition()
-		return nil
+		return err
 	})
 	photon.KeyBindings.Add(states.Normal, "r", func() error {
 		photon.DownloadFeeds()
@@ -345,7 +419,7 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		return nil
 	})
 	photon.KeyBindings.Add(states.Normal, "o", func() error {
-		return SelectedCard.OpenBrowser()
+		return SelectedCard.OpenLink(lib.LinkBrowser)
 	})
 	photon.KeyBindings.Add(states.Normal, "<esc>", func() error {
 		if command == "" {
@@ -393,14 +467,73 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		redraw(false)
 		return nil
 	})
-	// copy item link
-	photon.KeyBindings.Add(states.Normal, "yy", func() error {
-		if SelectedCard == nil {
-			return nil
-		}
-		osc52(SelectedCard.Item.Link)
+	// history of opened articles and played media
+	photon.KeyBindings.Add(states.Normal, "<shift>h", func() error {
+		openHistory()
+		return nil
+	})
+	addHistoryKeyBindings(s)
+	// pick the format of the media
+	photon.KeyBindings.Add(states.Normal, "f", func() error {
+		openFormats(SelectedCard)
+		return nil
+	})
+	addFormatsKeyBindings(s)
+	// choose the enclosure or media element to play, download or copy
+	photon.KeyBindings.Add(states.Normal, "<shift>m", func() error {
+		openMediaItems(SelectedCard)
+		return nil
+	})
+	addMediaItemsKeyBindings(s)
+	// playback queue
+	photon.KeyBindings.Add(states.Normal, "a", func() error {
+		SelectedCard.Enqueue()
+		return nil
+	})
+	photon.KeyBindings.Add(states.Normal, "<shift>q", func() error {
+		openQueue()
+		return nil
+	})
+	addQueueKeyBindings(s)
+	addQueuePlaybackKeyBindings(states.Normal)
+	addQueuePlaybackKeyBindings(states.Article)
+	// downloads
+	photon.KeyBindings.Add(states.Normal, "<shift>d", func() error {
+		openDownloads()
+		return nil
+	})
+	addDownloadsKeyBindings(s)
+	// items matched by the auto-download rules
+	photon.KeyBindings.Add(states.Normal, "<shift>a", func() error {
+		openAutoDownload()
+		return nil
+	})
+	addAutoDownloadKeyBindings(s)
+	// players and commands started by photon
+	photon.KeyBindings.Add(states.Normal, "<shift>p", func() error {
+		openProcesses()
 		return nil
 	})
+	addProcessesKeyBindings(s)
+	// read or edit the article in the terminal
+	photon.KeyBindings.Add(states.Normal, "v", func() error {
+		SelectedCard.OpenInPager()
+		return nil
+	})
+	photon.KeyBindings.Add(states.Normal, "<shift>e", func() error {
+		SelectedCard.OpenInEditor()
+		return nil
+	})
+	// cycle the order of the cards
+	photon.KeyBindings.Add(states.Normal, "s", func() error {
+		orders := lib.SortOrders
+		next := orders[(slices.Index(orders, photon.SortBy())+1)%len(orders)]
+		photon.SetSortBy(next)
+		photon.StatusWithTimeout("Sorted by "+next, time.Second*2)
+		return nil
+	})
+	// copy the link, title, markdown link, media link, image link or article text of the item
+	addYankKeyBindings()
 	// download media
 	photon.KeyBindings.Add(states.Normal, "dm", func() error {
 		SelectedCard.DownloadMedia()
@@ -416,6 +549,24 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		SelectedCard.DownloadImage()
 		return nil
 	})
+	// export article
+	photon.KeyBindings.Add(states.Normal, "em", func() error {
+		SelectedCard.Export(lib.ExportMarkdown)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Normal, "eh", func() error {
+		SelectedCard.Export(lib.ExportHTML)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Normal, "ee", func() error {
+		SelectedCard.Export(lib.ExportEPUB)
+		return nil
+	})
+	// export the visible cards to one epub
+	photon.KeyBindings.Add(states.Normal, "eb", func() error {
+		photon.ExportEPUB(photon.VisibleCards)
+		return nil
+	})
 	// move selectedCard
 	photon.KeyBindings.Add(states.Normal, "h", func() error {
 		grid.SelectedChildMoveLeft()
@@ -509,29 +660,69 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 
 	// ArticleState
 	photon.KeyBindings.Add(states.Article, "<esc>", func() error {
-		openedArticle = nil
-		photon.OpenedArticle = nil
-		s.Clear()
-		redraw(true)
+		if openedArticle != nil && openedArticle.search != "" {
+			openedArticle.ClearSearch()
+			redraw(false)
+			return nil
+		}
+		closeArticle(s)
 		return nil
 	})
 	photon.KeyBindings.Add(states.Article, "q", func() error {
-		openedArticle = nil
-		photon.OpenedArticle = nil
-		s.Clear()
-		redraw(true)
+		closeArticle(s)
 		return nil
 	})
-	photon.KeyBindings.Add(states.Normal, "yy", func() error {
-		// copy article link
+	photon.KeyBindings.Add(states.Article, "o", func() error {
+		return openedArticle.Card.OpenLink(lib.LinkBrowser)
+	})
+	// export article
+	photon.KeyBindings.Add(states.Article, "em", func() error {
 		if openedArticle == nil {
 			return nil
 		}
-		osc52(openedArticle.Card.Item.Link)
+		openedArticle.Card.Export(lib.ExportMarkdown)
 		return nil
 	})
-	photon.KeyBindings.Add(states.Article, "o", func() error {
-		openedArticle.Card.OpenBrowser()
+	photon.KeyBindings.Add(states.Article, "eh", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.Card.Export(lib.ExportHTML)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "ee", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.Card.Export(lib.ExportEPUB)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "f", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openFormats(openedArticle.Card)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "v", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.Card.OpenInPager()
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "<shift>e", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.Card.OpenInEditor()
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "<shift>m", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openMediaItems(openedArticle.Card)
 		return nil
 	})
 	photon.KeyBindings.Add(states.Article, "m", func() error {
@@ -558,6 +749,22 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		redraw(false)
 		return nil
 	})
+	photon.KeyBindings.Add(states.Article, "h", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.ScrollHorizontal(-8)
+		redraw(false)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "l", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.ScrollHorizontal(8)
+		redraw(false)
+		return nil
+	})
 	photon.KeyBindings.Add(states.Article, "gg", func() error {
 		if openedArticle == nil {
 			return nil
@@ -606,8 +813,44 @@ func defaultKeyBindings(s tcell.Screen, grid *Grid, quit *context.CancelFunc) {
 		redraw(true)
 		return nil
 	})
+	photon.KeyBindings.Add(states.Article, "/", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.StartSearch()
+		redraw(false)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "n", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.NextMatch(1)
+		redraw(true)
+		return nil
+	})
+	photon.KeyBindings.Add(states.Article, "<shift>n", func() error {
+		if openedArticle == nil {
+			return nil
+		}
+		openedArticle.NextMatch(-1)
+		redraw(true)
+		return nil
+	})
+
+	// ArticleSearchState
+	photon.KeyBindings.Add(states.ArticleSearch, "<enter>", func() error {
+		openedArticle.searchFocus = false
+		redraw(false)
+		return nil
+	})
+	photon.KeyBindings.Add(states.ArticleSearch, "<esc>", func() error {
+		openedArticle.ClearSearch()
+		redraw(false)
+		return nil
+	})
 }
 
-func setTerminalTitle(title string) {
-	fmt.Fprintf(os.Stdout, "\033]2;%s\007", title)
+func setTerminalTitle(w io.Writer, title string) {
+	fmt.Fprintf(w, "\033]2;%s\007", title)
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+)
+
+// the card and the media items shown in the media chooser
+var (
+	mediaItemsCard *lib.Card
+	mediaItems     []lib.MediaItem
+)
+
+// openMediaItems opens the media chooser with the enclosures and media elements of the card
+func openMediaItems(card *lib.Card) {
+	if card == nil {
+		return
+	}
+	items := card.MediaItems()
+	if len(items) == 0 {
+		photon.StatusWithTimeout("No media", time.Second*3)
+		return
+	}
+	mediaItemsCard, mediaItems = card, items
+	openList(&List{
+		Title: "Media · " + card.Item.Title,
+		State: states.MediaItems,
+		Rows:  mediaItemRows,
+	})
+}
+
+func mediaItemRows() []Richtext {
+	rows := make([]Richtext, len(mediaItems))
+	for i, mi := range mediaItems {
+		medium := mi.Medium
+		if medium == "" {
+			medium = "media"
+		}
+		rows[i] = Richtext{
+			{Text: medium + "  ", Style: prefixStyle},
+			{Text: mediaItemInfo(mi), Style: tcell.StyleDefault.Bold(true)},
+			{Text: " · " + mi.URL, Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// selectedMediaItem returns the selected media item
+func selectedMediaItem() (lib.MediaItem, bool) {
+	if openedList == nil || mediaItemsCard == nil || openedList.Selected() >= len(mediaItems) {
+		return lib.MediaItem{}, false
+	}
+	return mediaItems[openedList.Selected()], true
+}
+
+func addMediaItemsKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.MediaItems, s)
+	play := func() error {
+		mi, ok := selectedMediaItem()
+		if !ok {
+			return nil
+		}
+		card := mediaItemsCard
+		closeList(s)
+		card.PlayMediaItem(mi)
+		return nil
+	}
+	photon.KeyBindings.Add(states.MediaItems, "<enter>", play)
+	photon.KeyBindings.Add(states.MediaItems, "p", play)
+	photon.KeyBindings.Add(states.MediaItems, "d", func() error {
+		if mi, ok := selectedMediaItem(); ok {
+			mediaItemsCard.DownloadMediaItem(mi)
+		}
+		return nil
+	})
+	// copy the media link
+	photon.KeyBindings.Add(states.MediaItems, "y", func() error {
+		if mi, ok := selectedMediaItem(); ok {
+			if err := copyToClipboard(mi.URL); err != nil {
+				photon.StatusWithTimeout("ERROR: copying the media link: "+err.Error(), time.Second*3)
+				return nil
+			}
+			photon.StatusWithTimeout("Copied "+mi.URL, time.Second*2)
+		}
+		return nil
+	})
+}
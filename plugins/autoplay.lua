@@ -1,6 +1,7 @@
 --this plugins plays the media on the selected cars on <ctrl>p
 --when the player ended, moves the selected card to the right
 --so user can type 10<ctrl>p and play the next 10 items
+--(the playback queue, a and <shift>q, plays the items in one mpv window)
 photon = require("photon")
 
 run = 0
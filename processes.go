@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+	htime "github.com/sbani/go-humanizer/time"
+)
+
+// processList are the processes shown in the processes view, it's updated on every draw
+var processList []media.Process
+
+func openProcesses() {
+	openList(&List{
+		Title: "Processes",
+		State: states.Processes,
+		Rows:  processRows,
+	})
+}
+
+func processRows() []Richtext {
+	processList = photon.Processes().List()
+	rows := make([]Richtext, len(processList))
+	for i, proc := range processList {
+		name := proc.Title
+		if card := photon.ProcessCard(proc); card != nil {
+			name = card.Item.Title
+		}
+		if name == "" {
+			name = proc.Command
+		}
+		rows[i] = Richtext{
+			{Text: fmt.Sprintf("%-8s %-9s ", proc.Status, proc.Kind), Style: prefixStyle},
+			{Text: name, Style: tcell.StyleDefault.Bold(true)},
+			{Text: processInfo(proc), Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// processInfo returns the pid and the start time of the process, the error of a failed process,
+// or the last line of the stderr
+func processInfo(proc media.Process) string {
+	info := fmt.Sprintf(" · pid %d · %s", proc.Pid, htime.Difference(time.Now(), proc.StartedAt))
+	if proc.Error != "" {
+		return info + " · " + proc.Error
+	}
+	stderr := strings.TrimSpace(proc.Stderr())
+	if i := strings.LastIndexByte(stderr, '\n'); i >= 0 {
+		stderr = stderr[i+1:]
+	}
+	if stderr != "" {
+		info += " · " + strings.TrimSpace(stderr)
+	}
+	return info
+}
+
+// selectedProcess returns the selected process
+func selectedProcess() (media.Process, bool) {
+	if openedList == nil || openedList.Selected() >= len(processList) {
+		return media.Process{}, false
+	}
+	return processList[openedList.Selected()], true
+}
+
+func addProcessesKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.Processes, s)
+	// kill the running process, or remove the failed one
+	photon.KeyBindings.Add(states.Processes, "x", func() error {
+		if proc, ok := selectedProcess(); ok {
+			photon.Processes().Kill(proc.ID)
+		}
+		return nil
+	})
+	// copy the stderr of the process
+	photon.KeyBindings.Add(states.Processes, "y", func() error {
+		if proc, ok := selectedProcess(); ok {
+			if err := copyToClipboard(proc.Command + "\n" + proc.Stderr()); err != nil {
+				photon.StatusWithTimeout("ERROR: copying the stderr: "+err.Error(), time.Second*3)
+				return nil
+			}
+			photon.StatusWithTimeout("Copied the stderr of "+proc.Command, time.Second*2)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~ghost08/photon/lib"
+	"git.sr.ht/~ghost08/photon/lib/media"
+	"git.sr.ht/~ghost08/photon/lib/states"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// queueSeekStep is how far the seek keys move the playback
+	queueSeekStep = 10 * time.Second
+	// queueTitleWidth is the maximum width of the title in the status bar
+	queueTitleWidth = 30
+)
+
+func openQueue() {
+	openList(&List{
+		Title: "Queue",
+		State: states.Queue,
+		Rows:  queueRows,
+	})
+}
+
+func queueRows() []Richtext {
+	items, current := photon.Queue().Items()
+	rows := make([]Richtext, len(items))
+	for i, item := range items {
+		mark := "  "
+		if i == current {
+			mark = "▶ "
+		}
+		info := ""
+		if card := lib.QueuedCard(item); card != nil {
+			if card.Feed != nil {
+				info = " · " + card.Feed.Title
+			}
+			if d := card.Duration(); d > 0 {
+				info += " · " + media.FormatDuration(d)
+			}
+		}
+		rows[i] = Richtext{
+			{Text: mark, Style: prefixStyle},
+			{Text: item.Title, Style: tcell.StyleDefault.Bold(true)},
+			{Text: info, Style: tcell.StyleDefault.Italic(true)},
+		}
+	}
+	return rows
+}
+
+// queueStatus returns the now playing item with it's progress for the status bar
+func queueStatus() Richtext {
+	st := photon.Queue().Status()
+	if st.Item == nil {
+		return nil
+	}
+	icon := "▶"
+	if st.Paused {
+		icon = "⏸"
+	}
+	progress := media.FormatDuration(st.Position)
+	if st.Duration > 0 {
+		progress += "/" + media.FormatDuration(st.Duration)
+	}
+	return Richtext{{
+		Text:  fmt.Sprintf("%s %s %s [%d/%d] ", icon, runewidth.Truncate(st.Item.Title, queueTitleWidth, "…"), progress, st.Index+1, st.Len),
+		Style: tcell.StyleDefault,
+	}}
+}
+
+// addQueuePlaybackKeyBindings registers the keys controlling the playback in the state
+func addQueuePlaybackKeyBindings(state states.Enum) {
+	q := photon.Queue()
+	photon.KeyBindings.Add(state, " ", func() error {
+		go q.TogglePause()
+		return nil
+	})
+	photon.KeyBindings.Add(state, ".", func() error {
+		go q.Next()
+		return nil
+	})
+	photon.KeyBindings.Add(state, ",", func() error {
+		go q.Prev()
+		return nil
+	})
+	photon.KeyBindings.Add(state, "]", func() error {
+		go q.Seek(queueSeekStep)
+		return nil
+	})
+	photon.KeyBindings.Add(state, "[", func() error {
+		go q.Seek(-queueSeekStep)
+		return nil
+	})
+}
+
+func addQueueKeyBindings(s tcell.Screen) {
+	addListKeyBindings(states.Queue, s)
+	addQueuePlaybackKeyBindings(states.Queue)
+	q := photon.Queue()
+	// play the selected item
+	photon.KeyBindings.Add(states.Queue, "<enter>", func() error {
+		if openedList != nil {
+			go q.Play(openedList.Selected())
+		}
+		return nil
+	})
+	photon.KeyBindings.Add(states.Queue, "x", func() error {
+		if openedList != nil {
+			go q.Remove(openedList.Selected())
+		}
+		return nil
+	})
+	// move the selected item down or up
+	move := func(d int) func() error {
+		return func() error {
+			if openedList == nil {
+				return nil
+			}
+			i := openedList.Selected()
+			if i+d < 0 || i+d >= q.Len() {
+				return nil
+			}
+			q.Move(i, i+d)
+			openedList.Move(d)
+			return nil
+		}
+	}
+	photon.KeyBindings.Add(states.Queue, "<shift>j", move(1))
+	photon.KeyBindings.Add(states.Queue, "<shift>k", move(-1))
+}
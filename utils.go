@@ -1,10 +1,8 @@
 package main
 
 import (
-	"encoding/base64"
 	"fmt"
 	"image"
-	"os"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
@@ -144,8 +142,26 @@ func drawString(s tcell.Screen, x, y int, text string, style tcell.Style) (width
 	return
 }
 
-func osc52(text string) {
-	fmt.Fprint(os.Stderr, "\033]52;c;") // Start OSC52
-	base64.NewEncoder(base64.StdEncoding, os.Stderr).Write([]byte(text))
-	fmt.Fprint(os.Stderr, "\a") // End OSC52
+// drawRichtext draws one line of richtext, skipping the first offset cells
+// and clipping it to maxWidth
+func drawRichtext(s tcell.Screen, x, y, maxWidth, offset int, line Richtext) {
+	var col int
+	for _, to := range line {
+		for _, c := range to.Text {
+			var comb []rune
+			w := runewidth.RuneWidth(c)
+			if w == 0 {
+				comb = []rune{c}
+				c = ' '
+				w = 1
+			}
+			if col >= offset && col-offset+w <= maxWidth {
+				s.SetContent(x+col-offset, y, c, comb, to.Style)
+			}
+			col += w
+			if col-offset >= maxWidth {
+				return
+			}
+		}
+	}
 }